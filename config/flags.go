@@ -30,10 +30,15 @@ var (
 )
 
 var (
-	MirrorFlag stringArray
-	FilterFlag stringArray
-	RotateFlag stringArray
-	CropFlag   stringArray
+	MirrorFlag      stringArray
+	FilterFlag      stringArray
+	RotateFlag      stringArray
+	CropFlag        stringArray
+	ResizeFlag      stringArray
+	DeskewFlag      bool
+	AffineFlag      stringArray
+	PerspectiveFlag stringArray
+	OrientFlag      stringArray
 )
 
 var (
@@ -41,6 +46,13 @@ var (
 	OutputFileName string
 )
 
+// Options for saving at a lower bit depth.
+var (
+	BitDepth    int
+	PaletteFlag string
+	DitherFlag  string
+)
+
 var OrderedFlags []string
 
 func InitFlags() {
@@ -81,6 +93,14 @@ func handleApply() {
 	ApplyCmd.Var(&FilterFlag, "filter", "applies a filter to the image")
 	ApplyCmd.Var(&RotateFlag, "rotate", "rotates the image")
 	ApplyCmd.Var(&CropFlag, "crop", "crops the image")
+	ApplyCmd.Var(&ResizeFlag, "resize", "resizes the image")
+	ApplyCmd.BoolVar(&DeskewFlag, "deskew", false, "straightens skewed text")
+	ApplyCmd.Var(&AffineFlag, "affine", "maps the image through a 2x3 matrix")
+	ApplyCmd.Var(&PerspectiveFlag, "perspective", "maps four corners onto a rectangle")
+	ApplyCmd.Var(&OrientFlag, "orient", "turns an EXIF orientation into the normal one")
+	ApplyCmd.IntVar(&BitDepth, "bpp", 24, "bits per pixel of the saved image")
+	ApplyCmd.StringVar(&PaletteFlag, "palette", "", "palette of an indexed image")
+	ApplyCmd.StringVar(&DitherFlag, "dither", "none", "dithering used to reach the palette")
 	ApplyCmd.Usage = func() {
 		fmt.Print(applyHelpText)
 	}
@@ -163,6 +183,24 @@ func validateApply() bool {
 		return false
 	}
 
+	if BitDepth != 1 && BitDepth != 4 && BitDepth != 8 && BitDepth != 24 {
+		_, _ = fmt.Fprintln(os.Stderr, "ERROR: unsupported bits per pixel, use 1, 4, 8 or 24")
+		return false
+	}
+
+	if BitDepth == 24 {
+		invalid := false
+		ApplyCmd.Visit(func(f *flag.Flag) {
+			if f.Name == "palette" || f.Name == "dither" {
+				_, _ = fmt.Fprintf(os.Stderr, "ERROR: --%s needs --bpp=1, 4 or 8\n", f.Name)
+				invalid = true
+			}
+		})
+		if invalid {
+			return false
+		}
+	}
+
 	return true
 }
 
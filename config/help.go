@@ -20,8 +20,30 @@ var applyHelpText = `Usage:
 
 The options are:
   --help      prints program usage information
-  --mirror    mirrors the image
-  --filter    applies a filter to the image
-  --rotate    rotates the image
+  --mirror    mirrors the image: horizontally, vertically, diagonal
+              (transpose) or antidiagonal (transverse)
+  --filter    applies a filter to the image, parameters follow the name:
+              --filter=blur:radius=3 --filter=pixelate:size=8
+  --rotate    rotates the image: right, left, 90, 180, 270 or any
+              angle in degrees, with optional
+              :interp=nearest|bilinear|bicubic|lanczos
+              :expand=true|false :bg=<color>
   --crop      crops the image
+  --resize    resizes the image: WxH, Wx, xH or N%, with optional
+              :mode=stretch|fit|fill and
+              :kernel=nearest|bilinear|bicubic|lanczos
+  --deskew    detects the angle of skewed text and straightens it
+  --affine    maps the image through a 2x3 matrix: a,b,c,d,e,f with
+              optional :expand=true|false :interp=... :bg=<color>
+  --perspective
+              maps four corners (top-left, top-right, bottom-right,
+              bottom-left) onto a rectangle: x1,y1,...,x4,y4 with
+              optional :size=WxH :interp=... :bg=<color>
+  --orient    turns an image with EXIF orientation 1-8 upright
+  --bpp       bits per pixel of the saved image: 24 (default), 8, 4 or 1
+  --palette   palette for 1, 4 and 8 bits per pixel: bw, gray, vga, websafe,
+              a list of colors like 000000,ff8800,ffffff, or one built
+              from the image with mediancut, octree or kmeans
+  --dither    dithering for 1, 4 and 8 bits per pixel:
+              none (default), floyd, atkinson, jjn, bayer
 `
@@ -16,6 +16,8 @@ type BitMap struct {
 	header     *BMPHeader
 	infoHeader *DIBHeader
 	pixels     [][]*Pixel
+	palette    []Pixel
+	paletteBPP uint16
 }
 
 type BMPHeader struct {
@@ -154,6 +156,15 @@ func (b *BitMap) SetImageSize(imageSize uint32) {
 	b.infoHeader.ImageSize = imageSize
 }
 
+// UpdateSizes recomputes the image size and file size in the headers from
+// the current dimensions, including the padding of every row to 4 bytes.
+func (b *BitMap) UpdateSizes() {
+	height, width := b.GetDimensions()
+	stride := (uint32(width)*3 + 3) / 4 * 4
+	b.infoHeader.ImageSize = stride * uint32(height)
+	b.header.FileSize = b.header.BitmapOffset + b.infoHeader.ImageSize + uint32(len(lastData))
+}
+
 func (b *BitMap) GetFileSize() uint32 {
 	return b.header.FileSize
 }
@@ -162,7 +173,23 @@ func (b *BitMap) SetFileSize(fileSize uint32) {
 	b.header.FileSize = fileSize
 }
 
+// SetPalette makes Save write an indexed bitmap with the given bit depth
+// (1, 4 or 8) and color table. Every pixel is stored as the index of the
+// nearest palette color. A nil palette restores 24-bit output.
+func (b *BitMap) SetPalette(bitsPerPixel uint16, palette []Pixel) {
+	b.palette, b.paletteBPP = palette, bitsPerPixel
+}
+
+func (b *BitMap) GetPalette() []Pixel {
+	return b.palette
+}
+
 func (b *BitMap) Save(w io.Writer) {
+	if b.palette != nil {
+		b.saveIndexed(w)
+		return
+	}
+
 	err := binary.Write(w, binary.LittleEndian, b.header)
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v \n", err)
@@ -210,6 +237,89 @@ func (b *BitMap) Save(w io.Writer) {
 	}
 }
 
+// saveIndexed writes the image with a plain 40-byte info header followed by
+// the color table and one palette index per pixel. Extra header bytes and
+// trailing data of the source file are dropped, since they describe the
+// original 24-bit layout.
+func (b *BitMap) saveIndexed(w io.Writer) {
+	bpp := int(b.paletteBPP)
+	height, width := len(b.pixels), 0
+	if height > 0 {
+		width = len(b.pixels[0])
+	}
+	stride := (width*bpp + 31) / 32 * 4
+
+	info := *b.infoHeader
+	info.HeaderSize = 40
+	info.BitsPerPixel = b.paletteBPP
+	info.Compression = 0
+	info.ImageSize = uint32(stride * height)
+	info.ColorsUsed = uint32(len(b.palette))
+	info.ColorsImportant = 0
+
+	header := *b.header
+	header.BitmapOffset = 14 + 40 + 4*uint32(len(b.palette))
+	header.FileSize = header.BitmapOffset + info.ImageSize
+
+	err := binary.Write(w, binary.LittleEndian, &header)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v \n", err)
+		os.Exit(1)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, &info)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v \n", err)
+		os.Exit(1)
+	}
+
+	table := make([]byte, 0, 4*len(b.palette))
+	for _, c := range b.palette {
+		table = append(table, c.Blue, c.Green, c.Red, 0)
+	}
+	err = binary.Write(w, binary.LittleEndian, table)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v \n", err)
+		os.Exit(1)
+	}
+
+	cache := make(map[Pixel]byte)
+	row := make([]byte, stride)
+	for _, pixels := range b.pixels {
+		clear(row)
+		for x, pixel := range pixels {
+			index, ok := cache[*pixel]
+			if !ok {
+				index = nearestIndex(b.palette, *pixel)
+				cache[*pixel] = index
+			}
+			// pack the index into the row, leftmost pixel in the high bits
+			bit := x * bpp
+			row[bit/8] |= index << (8 - bpp - bit%8)
+		}
+		err = binary.Write(w, binary.LittleEndian, row)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v \n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// nearestIndex returns the index of the palette color closest to p.
+func nearestIndex(palette []Pixel, p Pixel) byte {
+	best, bestDist := 0, -1
+	for i, c := range palette {
+		dr := int(c.Red) - int(p.Red)
+		dg := int(c.Green) - int(p.Green)
+		db := int(c.Blue) - int(p.Blue)
+		dist := dr*dr + dg*dg + db*db
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return byte(best)
+}
+
 func (b *BMPHeader) Read(r io.Reader) (err error) {
 	err = binary.Read(r, binary.LittleEndian, b)
 	if err != nil {
@@ -0,0 +1,138 @@
+package deskew
+
+import (
+	"fmt"
+	"math"
+
+	"bitmap/config"
+	"bitmap/internal/core"
+	"bitmap/internal/filter"
+	"bitmap/internal/rotate"
+)
+
+// maxAngle is the largest skew in degrees that is searched for.
+const maxAngle = 15
+
+// maxPoints bounds the number of ink pixels scored for every angle, larger
+// scans are sampled evenly.
+const maxPoints = 200000
+
+// background fills the corners uncovered by the correction, scans are
+// usually printed on white paper.
+var background = core.Pixel{Red: 255, Green: 255, Blue: 255}
+
+func HandleDeskew(b *core.BitMap) {
+	if !config.DeskewFlag {
+		return
+	}
+
+	angle := DetectSkew(b)
+	fmt.Printf("Deskew: detected angle %.2f degrees\n", angle)
+	if math.Abs(angle) < 0.01 {
+		return
+	}
+
+	pixels := rotate.RotateAngle(b.GetPixels(), -angle, "bicubic", false, background)
+	b.SetPixels(pixels)
+	b.UpdateSizes()
+}
+
+// DetectSkew estimates the clockwise angle in degrees of the text lines in
+// the image. It binarizes a copy at the Otsu level and searches for the
+// angle whose projection profile of ink pixels has the sharpest peaks,
+// first in steps of one degree and then finer around the best match.
+func DetectSkew(b *core.BitMap) float64 {
+	xs, ys := inkPoints(b)
+	if len(xs) == 0 {
+		return 0
+	}
+
+	best := 0.0
+	for _, step := range []float64{1, 0.1, 0.02} {
+		low, high := best-10*step, best+10*step
+		if step == 1 {
+			low, high = -maxAngle, maxAngle
+		}
+		bestScore := -1.0
+		for n := 0; low+float64(n)*step <= high+1e-9; n++ {
+			angle := low + float64(n)*step
+			if score := profileScore(xs, ys, angle); score > bestScore {
+				best, bestScore = angle, score
+			}
+		}
+	}
+	return best
+}
+
+// inkPoints returns the coordinates, with y growing downwards, of the
+// pixels on the less common side of the Otsu threshold.
+func inkPoints(b *core.BitMap) ([]float64, []float64) {
+	pixels := b.GetPixels()
+	h := len(pixels)
+	if h == 0 || len(pixels[0]) == 0 {
+		return nil, nil
+	}
+
+	var hist [256]int
+	for _, row := range pixels {
+		for _, p := range row {
+			hist[gray(p)]++
+		}
+	}
+	level := filter.OtsuThreshold(hist)
+
+	dark := 0
+	for i := 0; i <= level; i++ {
+		dark += hist[i]
+	}
+	total := h * len(pixels[0])
+	// ink is whichever side covers less of the page
+	inkIsDark := dark <= total-dark
+	count := dark
+	if !inkIsDark {
+		count = total - dark
+	}
+	stride := max(1, count/maxPoints)
+
+	var xs, ys []float64
+	seen := 0
+	for r, row := range pixels {
+		y := float64(h - 1 - r)
+		for x, p := range row {
+			if (gray(p) <= level) != inkIsDark {
+				continue
+			}
+			if seen%stride == 0 {
+				xs = append(xs, float64(x))
+				ys = append(ys, y)
+			}
+			seen++
+		}
+	}
+	return xs, ys
+}
+
+// profileScore projects the points onto lines tilted clockwise by angle and
+// sums the squared differences of neighboring bins. Straight text lines
+// that match the angle fall into few bins and give a high score.
+func profileScore(xs, ys []float64, angle float64) float64 {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	bins := make(map[int]int)
+	low, high := math.MaxInt, math.MinInt
+	for i := range xs {
+		r := int(math.Floor(ys[i]*cos - xs[i]*sin))
+		bins[r]++
+		low, high = min(low, r), max(high, r)
+	}
+
+	var score float64
+	for r := low; r <= high; r++ {
+		d := float64(bins[r+1] - bins[r])
+		score += d * d
+	}
+	return score
+}
+
+func gray(p *core.Pixel) int {
+	return int(0.3*float64(p.Red) + 0.59*float64(p.Green) + 0.11*float64(p.Blue) + 0.5)
+}
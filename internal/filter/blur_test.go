@@ -0,0 +1,97 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func randomPlane(w, h int, seed int64) *plane {
+	rng := rand.New(rand.NewSource(seed))
+	p := newPlane(w, h)
+	for i := range p.v {
+		p.v[i] = float64(rng.Intn(256))
+	}
+	return p
+}
+
+// windowBlur is the direct form of boxBlur that sums the whole window for
+// every value, the reference boxBlur is tested and benchmarked against.
+func (p *plane) windowBlur(radius int, edge EdgeMode) *plane {
+	out := newPlane(p.w, p.h)
+	norm := 1 / float64((2*radius+1)*(2*radius+1))
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			var sum float64
+			for dy := -radius; dy <= radius; dy++ {
+				row := edge.index(y+dy, p.h) * p.w
+				for dx := -radius; dx <= radius; dx++ {
+					sum += p.v[row+edge.index(x+dx, p.w)]
+				}
+			}
+			out.v[y*p.w+x] = sum * norm
+		}
+	}
+	return out
+}
+
+func TestBoxBlurMatchesWindowAverage(t *testing.T) {
+	p := randomPlane(37, 23, 1)
+	// the last radius is wider than the plane, so the window clamps on
+	// both sides
+	for _, radius := range []int{1, 2, 5, 30} {
+		got := p.boxBlur(radius, EdgeClamp)
+		want := p.windowBlur(radius, EdgeClamp)
+		for i := range want.v {
+			if math.Abs(got.v[i]-want.v[i]) > 1e-9 {
+				t.Fatalf("radius %d: value %d is %g, want %g", radius, i, got.v[i], want.v[i])
+			}
+		}
+	}
+}
+
+// BenchmarkBlur compares the sliding sum with the direct window average.
+func BenchmarkBlur(b *testing.B) {
+	p := randomPlane(400, 300, 1)
+	for _, radius := range []int{2, 5, 10, 30} {
+		b.Run(fmt.Sprintf("radius=%d/sliding", radius), func(b *testing.B) {
+			for range b.N {
+				p.boxBlur(radius, EdgeClamp)
+			}
+		})
+		b.Run(fmt.Sprintf("radius=%d/window", radius), func(b *testing.B) {
+			for range b.N {
+				p.windowBlur(radius, EdgeClamp)
+			}
+		})
+	}
+}
+
+// BenchmarkGaussian compares the three box approximation with convolving
+// the full 2D Gaussian kernel of radius 3*sigma.
+func BenchmarkGaussian(b *testing.B) {
+	p := randomPlane(400, 300, 1)
+	for _, sigma := range []float64{1, 3, 10} {
+		radius := int(math.Ceil(3 * sigma))
+		weights := gaussianWeights(sigma, radius)
+		size := len(weights)
+		k := Kernel{Width: size, Height: size, Values: make([]float64, size*size)}
+		for y, wy := range weights {
+			for x, wx := range weights {
+				k.Values[y*size+x] = wy * wx
+			}
+		}
+
+		b.Run(fmt.Sprintf("sigma=%g/boxes", sigma), func(b *testing.B) {
+			for range b.N {
+				p.gaussianBlur(sigma, EdgeClamp)
+			}
+		})
+		b.Run(fmt.Sprintf("sigma=%g/kernel", sigma), func(b *testing.B) {
+			for range b.N {
+				p.convolve(k, EdgeClamp)
+			}
+		})
+	}
+}
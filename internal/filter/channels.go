@@ -0,0 +1,66 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"bitmap/internal/core"
+)
+
+// ApplyChannelMixFilter computes every output channel as a weighted sum of
+// the input channels. The matrix has a row per output channel (red, green,
+// blue) with three weights, or four when the last one is an offset in
+// levels.
+func ApplyChannelMixFilter(b *core.BitMap, matrix []float64) error {
+	if len(matrix) != 9 && len(matrix) != 12 {
+		return fmt.Errorf("matrix needs 9 (3x3) or 12 (3x4) values, got %d", len(matrix))
+	}
+	cols := len(matrix) / 3
+	Cycle(b, func(pixel *core.Pixel) {
+		in := [3]float64{float64(pixel.Red), float64(pixel.Green), float64(pixel.Blue)}
+		var out [3]float64
+		for row := range out {
+			m := matrix[row*cols : (row+1)*cols]
+			out[row] = m[0]*in[0] + m[1]*in[1] + m[2]*in[2]
+			if cols == 4 {
+				out[row] += m[3]
+			}
+		}
+		pixel.Red, pixel.Green, pixel.Blue = toByte(out[0]), toByte(out[1]), toByte(out[2])
+	})
+	return nil
+}
+
+// ApplySwapFilter rearranges the channels. The order names the source of
+// the red, green and blue output in turn, so "bgr" swaps red and blue and
+// "rrr" copies red everywhere.
+func ApplySwapFilter(b *core.BitMap, order string) error {
+	order = strings.ToLower(order)
+	if len(order) != 3 || strings.Trim(order, "rgb") != "" {
+		return fmt.Errorf("channel order must be three of r, g, b like bgr, got %q", order)
+	}
+	var source [3]int
+	for i := range source {
+		source[i] = strings.IndexByte("rgb", order[i])
+	}
+	Cycle(b, func(pixel *core.Pixel) {
+		in := [3]byte{pixel.Red, pixel.Green, pixel.Blue}
+		pixel.Red, pixel.Green, pixel.Blue = in[source[0]], in[source[1]], in[source[2]]
+	})
+	return nil
+}
+
+// ApplyExtractFilter writes one channel ("r", "g" or "b") as a grayscale
+// image.
+func ApplyExtractFilter(b *core.BitMap, channel string) {
+	Cycle(b, func(pixel *core.Pixel) {
+		v := pixel.Red
+		switch channel {
+		case "g":
+			v = pixel.Green
+		case "b":
+			v = pixel.Blue
+		}
+		pixel.Red, pixel.Green, pixel.Blue = v, v, v
+	})
+}
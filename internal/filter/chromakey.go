@@ -0,0 +1,62 @@
+package filter
+
+import (
+	"math"
+
+	"bitmap/internal/core"
+)
+
+// ApplyChromaKeyFilter replaces the pixels whose hue and saturation are
+// close to the key color with the background color. Brightness is not
+// compared, so shadows on the screen are keyed out too. Pixels within
+// tolerance of the key are replaced fully, over the next softness they
+// blend from the background to their own color. Bitmaps have no alpha
+// channel here, so the background is always a solid color.
+func ApplyChromaKeyFilter(b *core.BitMap, key core.Pixel, tolerance, softness float64, background core.Pixel) {
+	keyHue, keySat, _ := RGBToHSV(key)
+	Cycle(b, func(pixel *core.Pixel) {
+		h, s, _ := RGBToHSV(*pixel)
+		keep := smoothstep(tolerance, tolerance+softness, keyDistance(h, s, keyHue, keySat))
+		pixel.Red = mix(background.Red, pixel.Red, keep)
+		pixel.Green = mix(background.Green, pixel.Green, keep)
+		pixel.Blue = mix(background.Blue, pixel.Blue, keep)
+	})
+}
+
+// keyDistance compares two colors by HSV hue in degrees and saturation in
+// percent. Both stay the same when a color gets darker or brighter. The
+// hue counts less for weakly saturated colors, where it is unreliable.
+func keyDistance(h, s, keyHue, keySat float64) float64 {
+	dh := math.Abs(h - keyHue)
+	if dh > 180 {
+		dh = 360 - dh
+	}
+	return math.Hypot(dh*math.Min(s, keySat), (s-keySat)*100)
+}
+
+// ApplyReplaceColorFilter shifts the colors close to from by the difference
+// between to and from, so the replaced area keeps its shading. Colors within
+// tolerance are shifted fully, over the next softness the shift fades out.
+func ApplyReplaceColorFilter(b *core.BitMap, from, to core.Pixel, tolerance, softness float64) {
+	dr := float64(to.Red) - float64(from.Red)
+	dg := float64(to.Green) - float64(from.Green)
+	db := float64(to.Blue) - float64(from.Blue)
+	Cycle(b, func(pixel *core.Pixel) {
+		d := math.Sqrt(colorDistance2(*pixel, from))
+		weight := 1 - smoothstep(tolerance, tolerance+softness, d)
+		if weight == 0 {
+			return
+		}
+		pixel.Red = toByte(float64(pixel.Red) + dr*weight)
+		pixel.Green = toByte(float64(pixel.Green) + dg*weight)
+		pixel.Blue = toByte(float64(pixel.Blue) + db*weight)
+	})
+}
+
+// colorDistance2 returns the squared RGB distance of two colors.
+func colorDistance2(a, b core.Pixel) float64 {
+	dr := float64(a.Red) - float64(b.Red)
+	dg := float64(a.Green) - float64(b.Green)
+	db := float64(a.Blue) - float64(b.Blue)
+	return dr*dr + dg*dg + db*db
+}
@@ -0,0 +1,168 @@
+package filter
+
+import (
+	"math"
+
+	"bitmap/internal/core"
+)
+
+// RGBToHSV converts a pixel to hue in degrees [0, 360) and saturation and
+// value in [0, 1].
+func RGBToHSV(p core.Pixel) (float64, float64, float64) {
+	r, g, b := float64(p.Red)/255, float64(p.Green)/255, float64(p.Blue)/255
+	high, low := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	delta := high - low
+
+	var s float64
+	if high > 0 {
+		s = delta / high
+	}
+	return hue(r, g, b, high, delta), s, high
+}
+
+// HSVToRGB converts hue in degrees and saturation and value in [0, 1] back
+// to a pixel.
+func HSVToRGB(h, s, v float64) core.Pixel {
+	r, g, b := hsvToRGB(h, s, v)
+	return core.Pixel{Red: toByte(r), Green: toByte(g), Blue: toByte(b)}
+}
+
+// RGBToHSL converts a pixel to hue in degrees [0, 360) and saturation and
+// lightness in [0, 1].
+func RGBToHSL(p core.Pixel) (float64, float64, float64) {
+	r, g, b := float64(p.Red)/255, float64(p.Green)/255, float64(p.Blue)/255
+	high, low := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	delta := high - low
+	l := (high + low) / 2
+
+	var s float64
+	if delta > 0 {
+		s = delta / (1 - math.Abs(2*l-1))
+	}
+	return hue(r, g, b, high, delta), s, l
+}
+
+// HSLToRGB converts hue in degrees and saturation and lightness in [0, 1]
+// back to a pixel.
+func HSLToRGB(h, s, l float64) core.Pixel {
+	c := (1 - math.Abs(2*l-1)) * s
+	// HSL and HSV share the same hue hexagon, only the chroma and the
+	// offset of the darkest channel differ
+	v := l + c/2
+	var sv float64
+	if v > 0 {
+		sv = c / v
+	}
+	return HSVToRGB(h, sv, v)
+}
+
+// hue returns the hue in degrees of a color whose largest channel is high
+// and whose chroma is delta.
+func hue(r, g, b, high, delta float64) float64 {
+	if delta == 0 {
+		return 0
+	}
+	var h float64
+	switch high {
+	case r:
+		h = math.Mod((g-b)/delta, 6)
+	case g:
+		h = (b-r)/delta + 2
+	default:
+		h = (r-g)/delta + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// hsvToRGB converts a hue in degrees and saturation and value in [0, 1] to
+// red, green and blue in [0, 255].
+func hsvToRGB(h, s, v float64) (float64, float64, float64) {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return (r + m) * 255, (g + m) * 255, (b + m) * 255
+}
+
+// ApplyHueFilter rotates the hue of every pixel by the given degrees and
+// keeps its saturation and lightness.
+func ApplyHueFilter(b *core.BitMap, degrees float64) {
+	Cycle(b, func(pixel *core.Pixel) {
+		h, s, l := RGBToHSL(*pixel)
+		*pixel = HSLToRGB(h+degrees, s, l)
+	})
+}
+
+// ApplySaturationFilter multiplies the saturation of every pixel by amount.
+// Zero gives a gray image, 1 leaves it unchanged.
+func ApplySaturationFilter(b *core.BitMap, amount float64) {
+	Cycle(b, func(pixel *core.Pixel) {
+		h, s, l := RGBToHSL(*pixel)
+		*pixel = HSLToRGB(h, math.Min(1, s*amount), l)
+	})
+}
+
+// skinHue and skinWidth describe the orange band of hues, in degrees, that
+// skin tones fall into.
+const (
+	skinHue   = 25
+	skinWidth = 25
+)
+
+// ApplyVibranceFilter changes the saturation by amount (-1..1), mostly for
+// muted colors, so already saturated colors do not clip. When raising it,
+// hues near skin tones get at most half the boost so faces do not turn
+// orange.
+func ApplyVibranceFilter(b *core.BitMap, amount float64) {
+	Cycle(b, func(pixel *core.Pixel) {
+		h, s, l := RGBToHSL(*pixel)
+		boost := amount * (1 - s)
+		if boost > 0 {
+			skin := math.Max(0, 1-math.Abs(h-skinHue)/skinWidth)
+			boost *= 1 - skin/2
+		}
+		s *= 1 + boost
+		*pixel = HSLToRGB(h, math.Max(0, math.Min(1, s)), l)
+	})
+}
+
+// ApplyColorizeFilter gives every pixel the same hue and saturation and
+// keeps its lightness. The amount (0..1) blends the result with the
+// original color.
+func ApplyColorizeFilter(b *core.BitMap, hue, saturation, amount float64) {
+	Cycle(b, func(pixel *core.Pixel) {
+		_, _, l := RGBToHSL(*pixel)
+		c := HSLToRGB(hue, saturation, l)
+		pixel.Red = mix(pixel.Red, c.Red, amount)
+		pixel.Green = mix(pixel.Green, c.Green, amount)
+		pixel.Blue = mix(pixel.Blue, c.Blue, amount)
+	})
+}
+
+// mix blends from a to b by t in [0, 1].
+func mix(a, b byte, t float64) byte {
+	return toByte(float64(a) + (float64(b)-float64(a))*t)
+}
@@ -0,0 +1,158 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"bitmap/internal/core"
+	"bitmap/internal/params"
+)
+
+// EdgeMode decides which source pixel is used when a kernel reaches past
+// the border of the image.
+type EdgeMode int
+
+const (
+	// EdgeClamp repeats the outermost row or column.
+	EdgeClamp EdgeMode = iota
+	// EdgeWrap continues from the opposite side of the image.
+	EdgeWrap
+	// EdgeMirror reflects the image at its border without repeating the edge.
+	EdgeMirror
+)
+
+var edgeModes = map[string]EdgeMode{
+	"clamp":  EdgeClamp,
+	"wrap":   EdgeWrap,
+	"mirror": EdgeMirror,
+}
+
+// edgeChoices lists the accepted names of an "edge" parameter.
+var edgeChoices = []string{"clamp", "wrap", "mirror"}
+
+func ParseEdgeMode(name string) (EdgeMode, error) {
+	mode, ok := edgeModes[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown edge mode %q", name)
+	}
+	return mode, nil
+}
+
+// index maps a coordinate that may lie outside [0, n) to a valid one.
+func (e EdgeMode) index(i, n int) int {
+	if i >= 0 && i < n {
+		return i
+	}
+	switch e {
+	case EdgeWrap:
+		i %= n
+		if i < 0 {
+			i += n
+		}
+		return i
+	case EdgeMirror:
+		if n == 1 {
+			return 0
+		}
+		period := 2 * (n - 1)
+		i %= period
+		if i < 0 {
+			i += period
+		}
+		if i >= n {
+			i = period - i
+		}
+		return i
+	default:
+		if i < 0 {
+			return 0
+		}
+		return n - 1
+	}
+}
+
+// edgeTable precomputes e.index for every coordinate in [-r, n+r) so the
+// inner loops of a convolution do not branch on the border.
+func (e EdgeMode) edgeTable(n, r int) []int {
+	table := make([]int, n+2*r)
+	for i := range table {
+		table[i] = e.index(i-r, n)
+	}
+	return table
+}
+
+// convolve returns a new plane where every value is the weighted sum of its
+// neighborhood in p. The kernel is laid over the neighborhood as written,
+// with its top row over the row above the pixel.
+func (p *plane) convolve(k Kernel, edge EdgeMode) *plane {
+	out := newPlane(p.w, p.h)
+	if p.w == 0 || p.h == 0 {
+		return out
+	}
+	rx, ry := k.Width/2, k.Height/2
+	xs := edge.edgeTable(p.w, rx)
+	ys := edge.edgeTable(p.h, ry)
+
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			var sum float64
+			for ky := 0; ky < k.Height; ky++ {
+				row := ys[y+ky] * p.w
+				weights := k.Values[ky*k.Width : (ky+1)*k.Width]
+				for kx, weight := range weights {
+					if weight != 0 {
+						sum += weight * p.v[row+xs[x+kx]]
+					}
+				}
+			}
+			out.v[y*p.w+x] = sum
+		}
+	}
+	return out
+}
+
+// Convolve applies k to every color channel of the image. Source values are
+// read from a copy of the image, so the result does not depend on scan
+// order. The bias is added to every output value before clamping.
+func Convolve(b *core.BitMap, k Kernel, edge EdgeMode, bias float64) {
+	planes := loadPlanes(b)
+	for i, p := range planes {
+		planes[i] = p.convolve(k, edge)
+		if bias != 0 {
+			for j := range planes[i].v {
+				planes[i].v[j] += bias
+			}
+		}
+	}
+	storePlanes(b, planes)
+}
+
+func applyKernelFilter(b *core.BitMap, p params.Values) error {
+	name, file := p.String("name"), p.String("file")
+
+	var k Kernel
+	switch {
+	case name != "" && file != "":
+		return fmt.Errorf("give either name or file, not both")
+	case file != "":
+		var err error
+		k, err = LoadKernel(file)
+		if err != nil {
+			return err
+		}
+	case name != "":
+		k = NamedKernel(name, p.Int("radius"))
+	default:
+		return fmt.Errorf("a kernel name or file is required")
+	}
+
+	if p.Bool("normalize") {
+		k = k.Normalized()
+	}
+	edge, err := ParseEdgeMode(p.String("edge"))
+	if err != nil {
+		return err
+	}
+	Convolve(b, k, edge, p.Float("bias"))
+	return nil
+}
@@ -0,0 +1,228 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"bitmap/internal/core"
+)
+
+// CubeLUT is a color lookup table loaded from an Adobe/Resolve .cube file.
+// A 1D table maps each channel on its own, a 3D table maps every color of
+// a Size x Size x Size grid, with red changing fastest.
+type CubeLUT struct {
+	Size      int
+	Is3D      bool
+	DomainMin [3]float64
+	DomainMax [3]float64
+	Table     [][3]float64
+}
+
+// LoadCubeLUT reads a .cube file. Comments start with '#'. The domain is
+// set by DOMAIN_MIN/DOMAIN_MAX per channel or by the Resolve keywords
+// LUT_1D_INPUT_RANGE/LUT_3D_INPUT_RANGE for all channels at once, other
+// keywords like TITLE are skipped.
+func LoadCubeLUT(path string) (*CubeLUT, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	l := &CubeLUT{DomainMax: [3]float64{1, 1, 1}}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		switch fields[0] {
+		case "LUT_1D_SIZE", "LUT_3D_SIZE":
+			if l.Size != 0 && l.Is3D != (fields[0] == "LUT_3D_SIZE") {
+				return nil, fmt.Errorf("%s:%d: a file cannot declare both LUT_1D_SIZE and LUT_3D_SIZE", path, line)
+			}
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%s:%d: %s needs one value", path, line, fields[0])
+			}
+			l.Size, err = strconv.Atoi(fields[1])
+			if err != nil || l.Size < 2 {
+				return nil, fmt.Errorf("%s:%d: invalid size %q", path, line, fields[1])
+			}
+			l.Is3D = fields[0] == "LUT_3D_SIZE"
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			v, err := parseTriple(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+			}
+			if fields[0] == "DOMAIN_MIN" {
+				l.DomainMin = v
+			} else {
+				l.DomainMax = v
+			}
+		case "LUT_1D_INPUT_RANGE", "LUT_3D_INPUT_RANGE":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("%s:%d: %s needs a minimum and a maximum", path, line, fields[0])
+			}
+			low, errLow := strconv.ParseFloat(fields[1], 64)
+			high, errHigh := strconv.ParseFloat(fields[2], 64)
+			if errLow != nil || errHigh != nil {
+				return nil, fmt.Errorf("%s:%d: invalid input range %q %q", path, line, fields[1], fields[2])
+			}
+			l.DomainMin = [3]float64{low, low, low}
+			l.DomainMax = [3]float64{high, high, high}
+		default:
+			if _, err := strconv.ParseFloat(fields[0], 64); err != nil {
+				// TITLE and vendor keywords
+				continue
+			}
+			v, err := parseTriple(fields)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+			}
+			l.Table = append(l.Table, v)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if l.Size == 0 {
+		return nil, fmt.Errorf("%s: missing LUT_1D_SIZE or LUT_3D_SIZE", path)
+	}
+	want := l.Size
+	if l.Is3D {
+		want = l.Size * l.Size * l.Size
+	}
+	if len(l.Table) != want {
+		return nil, fmt.Errorf("%s: expected %d table entries, got %d", path, want, len(l.Table))
+	}
+	for ch := range l.DomainMin {
+		if l.DomainMax[ch] <= l.DomainMin[ch] {
+			return nil, fmt.Errorf("%s: empty domain for channel %d", path, ch)
+		}
+	}
+	return l, nil
+}
+
+func parseTriple(fields []string) ([3]float64, error) {
+	var v [3]float64
+	if len(fields) != 3 {
+		return v, fmt.Errorf("expected three values, got %d", len(fields))
+	}
+	for i, f := range fields {
+		var err error
+		v[i], err = strconv.ParseFloat(f, 64)
+		if err != nil {
+			return v, fmt.Errorf("invalid value %q", f)
+		}
+	}
+	return v, nil
+}
+
+// ApplyCubeLUTFilter maps every pixel through the table. 3D tables are
+// interpolated trilinearly or, with tetrahedral set, inside one of the six
+// tetrahedra of the grid cell, which keeps neutral grays neutral.
+func ApplyCubeLUTFilter(b *core.BitMap, l *CubeLUT, tetrahedral bool) {
+	cache := make(map[core.Pixel]core.Pixel)
+	Cycle(b, func(pixel *core.Pixel) {
+		out, ok := cache[*pixel]
+		if !ok {
+			in := [3]float64{float64(pixel.Red) / 255, float64(pixel.Green) / 255, float64(pixel.Blue) / 255}
+			var v [3]float64
+			switch {
+			case !l.Is3D:
+				v = l.lookup1D(in)
+			case tetrahedral:
+				v = l.tetrahedral(in)
+			default:
+				v = l.trilinear(in)
+			}
+			out = core.Pixel{Red: toByte(v[0] * 255), Green: toByte(v[1] * 255), Blue: toByte(v[2] * 255)}
+			cache[*pixel] = out
+		}
+		*pixel = out
+	})
+}
+
+// gridPosition maps a value of a channel to its place on the grid: the
+// index of the lower grid point and the fraction towards the next one.
+func (l *CubeLUT) gridPosition(v float64, ch int) (int, float64) {
+	t := (v - l.DomainMin[ch]) / (l.DomainMax[ch] - l.DomainMin[ch]) * float64(l.Size-1)
+	t = clampFloat(t, 0, float64(l.Size-1))
+	i := min(int(t), l.Size-2)
+	return i, t - float64(i)
+}
+
+func (l *CubeLUT) lookup1D(in [3]float64) [3]float64 {
+	var out [3]float64
+	for ch := range in {
+		i, f := l.gridPosition(in[ch], ch)
+		out[ch] = l.Table[i][ch]*(1-f) + l.Table[i+1][ch]*f
+	}
+	return out
+}
+
+func (l *CubeLUT) at(r, g, b int) [3]float64 {
+	return l.Table[(b*l.Size+g)*l.Size+r]
+}
+
+func (l *CubeLUT) trilinear(in [3]float64) [3]float64 {
+	r, fr := l.gridPosition(in[0], 0)
+	g, fg := l.gridPosition(in[1], 1)
+	b, fb := l.gridPosition(in[2], 2)
+
+	var out [3]float64
+	for ch := range out {
+		c00 := l.at(r, g, b)[ch]*(1-fr) + l.at(r+1, g, b)[ch]*fr
+		c10 := l.at(r, g+1, b)[ch]*(1-fr) + l.at(r+1, g+1, b)[ch]*fr
+		c01 := l.at(r, g, b+1)[ch]*(1-fr) + l.at(r+1, g, b+1)[ch]*fr
+		c11 := l.at(r, g+1, b+1)[ch]*(1-fr) + l.at(r+1, g+1, b+1)[ch]*fr
+		c0 := c00*(1-fg) + c10*fg
+		c1 := c01*(1-fg) + c11*fg
+		out[ch] = c0*(1-fb) + c1*fb
+	}
+	return out
+}
+
+func (l *CubeLUT) tetrahedral(in [3]float64) [3]float64 {
+	r, fr := l.gridPosition(in[0], 0)
+	g, fg := l.gridPosition(in[1], 1)
+	b, fb := l.gridPosition(in[2], 2)
+
+	c000 := l.at(r, g, b)
+	c111 := l.at(r+1, g+1, b+1)
+	// walk from the lower to the upper corner of the cell along the axes in
+	// order of their fractions, passing two more corners on the way
+	var first, second [3]float64
+	var w0, w1, w2, w3 float64
+	switch {
+	case fr >= fg && fg >= fb:
+		first, second = l.at(r+1, g, b), l.at(r+1, g+1, b)
+		w0, w1, w2, w3 = 1-fr, fr-fg, fg-fb, fb
+	case fr >= fb && fb >= fg:
+		first, second = l.at(r+1, g, b), l.at(r+1, g, b+1)
+		w0, w1, w2, w3 = 1-fr, fr-fb, fb-fg, fg
+	case fb >= fr && fr >= fg:
+		first, second = l.at(r, g, b+1), l.at(r+1, g, b+1)
+		w0, w1, w2, w3 = 1-fb, fb-fr, fr-fg, fg
+	case fg >= fr && fr >= fb:
+		first, second = l.at(r, g+1, b), l.at(r+1, g+1, b)
+		w0, w1, w2, w3 = 1-fg, fg-fr, fr-fb, fb
+	case fg >= fb && fb >= fr:
+		first, second = l.at(r, g+1, b), l.at(r, g+1, b+1)
+		w0, w1, w2, w3 = 1-fg, fg-fb, fb-fr, fr
+	default:
+		first, second = l.at(r, g, b+1), l.at(r, g+1, b+1)
+		w0, w1, w2, w3 = 1-fb, fb-fg, fg-fr, fr
+	}
+
+	var out [3]float64
+	for ch := range out {
+		out[ch] = w0*c000[ch] + w1*first[ch] + w2*second[ch] + w3*c111[ch]
+	}
+	return out
+}
@@ -0,0 +1,251 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"bitmap/internal/core"
+	"bitmap/internal/params"
+)
+
+// channelChoices lists the accepted values of a "channel" parameter.
+var channelChoices = []string{"rgb", "r", "g", "b"}
+
+// CurvePoint maps an input level to an output level, both in [0, 255].
+type CurvePoint struct {
+	X, Y float64
+}
+
+// applyChannelLUT maps the selected channel ("rgb", "r", "g" or "b")
+// through l and leaves the others unchanged.
+func applyChannelLUT(b *core.BitMap, channel string, l *lut) {
+	switch channel {
+	case "r":
+		applyLUTs(b, l, nil, nil)
+	case "g":
+		applyLUTs(b, nil, l, nil)
+	case "b":
+		applyLUTs(b, nil, nil, l)
+	default:
+		applyLUT(b, l)
+	}
+}
+
+// ApplyLevelsFilter remaps the input range [black, white] to
+// [outBlack, outWhite] and bends the midtones with gamma, like the levels
+// dialog of a photo editor.
+func ApplyLevelsFilter(b *core.BitMap, channel string, black, white, gamma, outBlack, outWhite float64) {
+	applyChannelLUT(b, channel, newLUT(func(v float64) float64 {
+		t := (v - black) / (white - black)
+		t = math.Max(0, math.Min(1, t))
+		return outBlack + math.Pow(t, 1/gamma)*(outWhite-outBlack)
+	}))
+}
+
+// ApplyCurvesFilter maps the selected channel through a smooth curve
+// passing through the given points.
+func ApplyCurvesFilter(b *core.BitMap, channel string, points []CurvePoint) {
+	applyChannelLUT(b, channel, newLUT(curve(points)))
+}
+
+// curve returns a monotone cubic spline (Fritsch-Carlson) through the
+// points, which are sorted by X. The spline does not overshoot between
+// points, so a rising set of points gives a rising curve. Outside the points
+// the curve stays flat.
+func curve(points []CurvePoint) func(float64) float64 {
+	n := len(points)
+	if n == 1 {
+		return func(float64) float64 { return points[0].Y }
+	}
+
+	slopes := make([]float64, n-1)
+	for i := range slopes {
+		slopes[i] = (points[i+1].Y - points[i].Y) / (points[i+1].X - points[i].X)
+	}
+	tangents := make([]float64, n)
+	tangents[0], tangents[n-1] = slopes[0], slopes[n-2]
+	for i := 1; i < n-1; i++ {
+		if slopes[i-1]*slopes[i] <= 0 {
+			continue
+		}
+		tangents[i] = (slopes[i-1] + slopes[i]) / 2
+	}
+	for i, s := range slopes {
+		if s == 0 {
+			tangents[i], tangents[i+1] = 0, 0
+			continue
+		}
+		a, c := tangents[i]/s, tangents[i+1]/s
+		if h := a*a + c*c; h > 9 {
+			t := 3 / math.Sqrt(h)
+			tangents[i], tangents[i+1] = t*a*s, t*c*s
+		}
+	}
+
+	return func(x float64) float64 {
+		if x <= points[0].X {
+			return points[0].Y
+		}
+		if x >= points[n-1].X {
+			return points[n-1].Y
+		}
+		i := sort.Search(n, func(i int) bool { return points[i].X > x }) - 1
+		dx := points[i+1].X - points[i].X
+		t := (x - points[i].X) / dx
+		t2, t3 := t*t, t*t*t
+		return (2*t3-3*t2+1)*points[i].Y + (t3-2*t2+t)*dx*tangents[i] +
+			(-2*t3+3*t2)*points[i+1].Y + (t3-t2)*dx*tangents[i+1]
+	}
+}
+
+// ParseCurvePoints parses points written as "x/y,x/y,...".
+func ParseCurvePoints(text string) ([]CurvePoint, error) {
+	var points []CurvePoint
+	for _, pair := range strings.Split(text, ",") {
+		xs, ys, ok := strings.Cut(pair, "/")
+		if !ok {
+			return nil, fmt.Errorf("curve point %q must be written as x/y", pair)
+		}
+		p, err := parseCurvePoint(xs, ys)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return checkCurve(points)
+}
+
+// LoadCurves reads curves from a text file with one "x y" point per line.
+// A line holding only a channel name in brackets ("[rgb]", "[r]", "[g]" or
+// "[b]") starts the curve of that channel, points before the first such line
+// belong to defaultChannel. Empty lines and lines starting with '#' are
+// ignored.
+func LoadCurves(path, defaultChannel string) (map[string][]CurvePoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	curves := make(map[string][]CurvePoint)
+	var order []string
+	channel := defaultChannel
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			channel = strings.ToLower(strings.Trim(text, "[] "))
+			if !validChannel(channel) {
+				return nil, fmt.Errorf("%s:%d: unknown channel %q", path, line, channel)
+			}
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected two values, got %d", path, line, len(fields))
+		}
+		p, err := parseCurvePoint(fields[0], fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if _, seen := curves[channel]; !seen {
+			order = append(order, channel)
+		}
+		curves[channel] = append(curves[channel], p)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(curves) == 0 {
+		return nil, fmt.Errorf("%s: no curve points", path)
+	}
+	for _, channel := range order {
+		if curves[channel], err = checkCurve(curves[channel]); err != nil {
+			return nil, fmt.Errorf("%s: [%s]: %v", path, channel, err)
+		}
+	}
+	return curves, nil
+}
+
+func validChannel(channel string) bool {
+	for _, c := range channelChoices {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCurvePoint(xs, ys string) (CurvePoint, error) {
+	x, err := strconv.ParseFloat(strings.TrimSpace(xs), 64)
+	if err != nil {
+		return CurvePoint{}, fmt.Errorf("invalid curve input %q", xs)
+	}
+	y, err := strconv.ParseFloat(strings.TrimSpace(ys), 64)
+	if err != nil {
+		return CurvePoint{}, fmt.Errorf("invalid curve output %q", ys)
+	}
+	if x < 0 || x > 255 || y < 0 || y > 255 {
+		return CurvePoint{}, fmt.Errorf("curve point %g/%g is outside 0..255", x, y)
+	}
+	return CurvePoint{X: x, Y: y}, nil
+}
+
+// checkCurve sorts the points and rejects repeated inputs.
+func checkCurve(points []CurvePoint) ([]CurvePoint, error) {
+	sort.Slice(points, func(i, j int) bool { return points[i].X < points[j].X })
+	for i := 1; i < len(points); i++ {
+		if points[i].X == points[i-1].X {
+			return nil, fmt.Errorf("curve has two points at input %g", points[i].X)
+		}
+	}
+	return points, nil
+}
+
+func applyLevelsFilter(b *core.BitMap, p params.Values) error {
+	black, white := p.Float("black"), p.Float("white")
+	if black >= white {
+		return fmt.Errorf("black point %g must be below white point %g", black, white)
+	}
+	ApplyLevelsFilter(b, p.String("channel"), black, white, p.Float("gamma"), p.Float("outblack"), p.Float("outwhite"))
+	return nil
+}
+
+func applyCurvesFilter(b *core.BitMap, p params.Values) error {
+	text, file := p.String("points"), p.String("file")
+	switch {
+	case text != "" && file != "":
+		return fmt.Errorf("give either points or file, not both")
+	case text != "":
+		points, err := ParseCurvePoints(text)
+		if err != nil {
+			return err
+		}
+		ApplyCurvesFilter(b, p.String("channel"), points)
+	case file != "":
+		curves, err := LoadCurves(file, p.String("channel"))
+		if err != nil {
+			return err
+		}
+		// the common curve goes first so per-channel curves refine it
+		for _, channel := range channelChoices {
+			if points, ok := curves[channel]; ok {
+				ApplyCurvesFilter(b, channel, points)
+			}
+		}
+	default:
+		return fmt.Errorf("curve points or a curve file are required")
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package filter
+
+import (
+	"math"
+
+	"bitmap/internal/core"
+)
+
+// ApplyMedianFilter replaces every channel value with the median of the
+// (2*radius+1)x(2*radius+1) window around it. It removes salt-and-pepper
+// noise while keeping edges sharp.
+func ApplyMedianFilter(b *core.BitMap, radius int) {
+	planes := loadPlanes(b)
+	for i, p := range planes {
+		planes[i] = p.median(radius)
+	}
+	storePlanes(b, planes)
+}
+
+// median keeps a histogram of the window and slides it along each row, so
+// moving one pixel only adds and removes a column of values.
+func (p *plane) median(radius int) *plane {
+	out := newPlane(p.w, p.h)
+	if p.w == 0 || p.h == 0 {
+		return out
+	}
+	xs := EdgeClamp.edgeTable(p.w, radius)
+	ys := EdgeClamp.edgeTable(p.h, radius)
+	half := (2*radius+1)*(2*radius+1)/2 + 1
+
+	for y := 0; y < p.h; y++ {
+		var hist [256]int
+		for ky := 0; ky <= 2*radius; ky++ {
+			for kx := 0; kx <= 2*radius; kx++ {
+				hist[toByte(p.at(xs[kx], ys[y+ky]))]++
+			}
+		}
+		for x := 0; x < p.w; x++ {
+			seen := 0
+			for level, n := range hist {
+				seen += n
+				if seen >= half {
+					out.set(x, y, float64(level))
+					break
+				}
+			}
+			if x+1 == p.w {
+				break
+			}
+			for ky := 0; ky <= 2*radius; ky++ {
+				row := ys[y+ky]
+				hist[toByte(p.at(xs[x], row))]--
+				hist[toByte(p.at(xs[x+2*radius+1], row))]++
+			}
+		}
+	}
+	return out
+}
+
+// ApplyBilateralFilter averages every pixel with its neighbors weighted
+// both by distance (spatial sigma) and by color difference (range sigma),
+// so similar colors are smoothed while edges between different colors stay.
+func ApplyBilateralFilter(b *core.BitMap, spatial, rangeSigma float64) {
+	planes := loadPlanes(b)
+	w, h := planes[0].w, planes[0].h
+	radius := int(math.Ceil(2 * spatial))
+
+	spatialWeights := make([]float64, (2*radius+1)*(2*radius+1))
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			d := float64(dx*dx + dy*dy)
+			spatialWeights[(dy+radius)*(2*radius+1)+dx+radius] = math.Exp(-d / (2 * spatial * spatial))
+		}
+	}
+	// range weights by squared color distance, which is at most 3*255*255
+	rangeWeights := make([]float64, 3*255*255+1)
+	for d := range rangeWeights {
+		rangeWeights[d] = math.Exp(-float64(d) / (2 * rangeSigma * rangeSigma))
+	}
+
+	xs := EdgeClamp.edgeTable(w, radius)
+	ys := EdgeClamp.edgeTable(h, radius)
+	out := [3]*plane{newPlane(w, h), newPlane(w, h), newPlane(w, h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			var sum [3]float64
+			var total float64
+			for ky := 0; ky <= 2*radius; ky++ {
+				for kx := 0; kx <= 2*radius; kx++ {
+					j := ys[y+ky]*w + xs[x+kx]
+					var d float64
+					for _, p := range planes {
+						diff := p.v[i] - p.v[j]
+						d += diff * diff
+					}
+					weight := spatialWeights[ky*(2*radius+1)+kx] * rangeWeights[int(d)]
+					for c, p := range planes {
+						sum[c] += weight * p.v[j]
+					}
+					total += weight
+				}
+			}
+			for c := range out {
+				out[c].v[i] = sum[c] / total
+			}
+		}
+	}
+	storePlanes(b, out)
+}
+
+// ApplyNLMeansFilter is a simple non-local means denoiser. Every pixel
+// becomes the average of the pixels in a search window around it, weighted
+// by how similar the patches around both pixels are. The strength h sets
+// how different patches may be and still count.
+//
+// The patch distances are computed one search offset at a time for the
+// whole image and summed with a box blur, so the patch size does not add
+// to the cost.
+func ApplyNLMeansFilter(b *core.BitMap, strength float64, patchRadius, searchRadius int) {
+	planes := loadPlanes(b)
+	w, h := planes[0].w, planes[0].h
+	xs := EdgeMirror.edgeTable(w, searchRadius)
+	ys := EdgeMirror.edgeTable(h, searchRadius)
+
+	sums := [3]*plane{newPlane(w, h), newPlane(w, h), newPlane(w, h)}
+	totals := newPlane(w, h)
+	diff := newPlane(w, h)
+	// distances are averaged over the patch and the three channels
+	h2 := strength * strength * 3
+
+	for dy := -searchRadius; dy <= searchRadius; dy++ {
+		for dx := -searchRadius; dx <= searchRadius; dx++ {
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					j := ys[y+dy+searchRadius]*w + xs[x+dx+searchRadius]
+					var d float64
+					for _, p := range planes {
+						v := p.v[y*w+x] - p.v[j]
+						d += v * v
+					}
+					diff.v[y*w+x] = d
+				}
+			}
+			patch := diff.boxBlur(patchRadius, EdgeMirror)
+
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					i := y*w + x
+					j := ys[y+dy+searchRadius]*w + xs[x+dx+searchRadius]
+					weight := math.Exp(-patch.v[i] / h2)
+					for c, p := range planes {
+						sums[c].v[i] += weight * p.v[j]
+					}
+					totals.v[i] += weight
+				}
+			}
+		}
+	}
+
+	for c := range sums {
+		for i := range sums[c].v {
+			sums[c].v[i] /= totals.v[i]
+		}
+	}
+	storePlanes(b, sums)
+}
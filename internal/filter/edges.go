@@ -0,0 +1,208 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+
+	"bitmap/internal/core"
+	"bitmap/internal/params"
+)
+
+// gradientOperators holds the horizontal kernels of the gradient filters,
+// the vertical kernel is the transpose.
+var gradientOperators = map[string]Kernel{
+	"sobel": {Width: 3, Height: 3, Values: []float64{
+		-1, 0, 1,
+		-2, 0, 2,
+		-1, 0, 1,
+	}},
+	"prewitt": {Width: 3, Height: 3, Values: []float64{
+		-1, 0, 1,
+		-1, 0, 1,
+		-1, 0, 1,
+	}},
+	"scharr": {Width: 3, Height: 3, Values: []float64{
+		-3, 0, 3,
+		-10, 0, 10,
+		-3, 0, 3,
+	}},
+}
+
+var laplacianKernels = map[int]Kernel{
+	4: {Width: 3, Height: 3, Values: []float64{
+		0, 1, 0,
+		1, -4, 1,
+		0, 1, 0,
+	}},
+	8: {Width: 3, Height: 3, Values: []float64{
+		1, 1, 1,
+		1, -8, 1,
+		1, 1, 1,
+	}},
+}
+
+// gradientParams are shared by the sobel, prewitt and scharr filters.
+var gradientParams = []params.Spec{
+	{Name: "mode", Kind: params.String, Default: "magnitude", Choices: []string{"magnitude", "direction"}},
+	{Name: "scale", Kind: params.Float, Default: "1", Min: 0.001, Max: 1000},
+	{Name: "edge", Kind: params.String, Default: "clamp", Choices: edgeChoices},
+}
+
+func (k Kernel) transposed() Kernel {
+	t := Kernel{Width: k.Height, Height: k.Width, Values: make([]float64, len(k.Values))}
+	for y := 0; y < k.Height; y++ {
+		for x := 0; x < k.Width; x++ {
+			t.Values[x*t.Width+y] = k.Values[y*k.Width+x]
+		}
+	}
+	return t
+}
+
+// gradient returns the horizontal and vertical derivatives of p.
+func (p *plane) gradient(operator Kernel, edge EdgeMode) (*plane, *plane) {
+	return p.convolve(operator, edge), p.convolve(operator.transposed(), edge)
+}
+
+// gradientFilter builds the registry function of a gradient operator.
+func gradientFilter(name string) func(*core.BitMap, params.Values) error {
+	return func(b *core.BitMap, p params.Values) error {
+		edge, err := ParseEdgeMode(p.String("edge"))
+		if err != nil {
+			return err
+		}
+		ApplyGradientFilter(b, name, p.String("mode") == "direction", p.Float("scale"), edge)
+		return nil
+	}
+}
+
+// ApplyGradientFilter replaces the image with the gradient magnitude of its
+// luminance computed by the named operator (sobel, prewitt or scharr).
+// When colored is set the hue shows the gradient direction and the
+// brightness its magnitude.
+func ApplyGradientFilter(b *core.BitMap, operator string, colored bool, scale float64, edge EdgeMode) {
+	gx, gy := luminance(loadPlanes(b)).gradient(gradientOperators[operator], edge)
+
+	out := [3]*plane{newPlane(gx.w, gx.h), newPlane(gx.w, gx.h), newPlane(gx.w, gx.h)}
+	for i := range gx.v {
+		magnitude := math.Hypot(gx.v[i], gy.v[i]) * scale
+		if !colored {
+			out[0].v[i], out[1].v[i], out[2].v[i] = magnitude, magnitude, magnitude
+			continue
+		}
+		angle := math.Atan2(gy.v[i], gx.v[i]) * 180 / math.Pi
+		out[0].v[i], out[1].v[i], out[2].v[i] = hsvToRGB(angle, 1, math.Min(magnitude/255, 1))
+	}
+	storePlanes(b, out)
+}
+
+// ApplyLaplacianFilter replaces the image with the absolute second
+// derivative of its luminance, using the 4- or 8-neighbor kernel.
+func ApplyLaplacianFilter(b *core.BitMap, neighbors int, scale float64, edge EdgeMode) {
+	l := luminance(loadPlanes(b)).convolve(laplacianKernels[neighbors], edge)
+	for i, v := range l.v {
+		l.v[i] = math.Abs(v) * scale
+	}
+	storePlanes(b, [3]*plane{l, l, l})
+}
+
+// ApplyCannyFilter draws the edges found by the Canny detector in white on
+// a black background. The luminance is smoothed with a Gaussian of the given
+// sigma, gradients below low are dropped, gradients above high start an edge
+// and the ones in between are kept only when connected to such an edge.
+func ApplyCannyFilter(b *core.BitMap, sigma, low, high float64) {
+	l := luminance(loadPlanes(b))
+	if sigma > 0 {
+		l = l.gaussianBlur(sigma, EdgeMirror)
+	}
+	gx, gy := l.gradient(gradientOperators["sobel"], EdgeClamp)
+	w, h := l.w, l.h
+
+	magnitude := newPlane(w, h)
+	for i := range magnitude.v {
+		magnitude.v[i] = math.Hypot(gx.v[i], gy.v[i])
+	}
+
+	// Non-maximum suppression: keep a pixel only if it is the strongest
+	// along its gradient direction.
+	thin := newPlane(w, h)
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			m := magnitude.at(x, y)
+			if m < low {
+				continue
+			}
+			dx, dy := gradientStep(gx.at(x, y), gy.at(x, y))
+			if m >= magnitude.at(x+dx, y+dy) && m >= magnitude.at(x-dx, y-dy) {
+				thin.set(x, y, m)
+			}
+		}
+	}
+
+	// Hysteresis: grow edges from the strong pixels through the weak ones.
+	edges := newPlane(w, h)
+	var stack []int
+	for i, m := range thin.v {
+		if m >= high {
+			edges.v[i] = 255
+			stack = append(stack, i)
+		}
+	}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		x, y := i%w, i/w
+		for ny := y - 1; ny <= y+1; ny++ {
+			for nx := x - 1; nx <= x+1; nx++ {
+				if nx < 0 || ny < 0 || nx >= w || ny >= h {
+					continue
+				}
+				j := ny*w + nx
+				if edges.v[j] == 0 && thin.v[j] >= low {
+					edges.v[j] = 255
+					stack = append(stack, j)
+				}
+			}
+		}
+	}
+	storePlanes(b, [3]*plane{edges, edges, edges})
+}
+
+// gradientStep rounds the gradient direction to one of the 8 neighbors.
+func gradientStep(gx, gy float64) (int, int) {
+	angle := math.Atan2(gy, gx) * 180 / math.Pi
+	if angle < 0 {
+		angle += 180
+	}
+	switch {
+	case angle < 22.5 || angle >= 157.5:
+		return 1, 0
+	case angle < 67.5:
+		return 1, 1
+	case angle < 112.5:
+		return 0, 1
+	default:
+		return -1, 1
+	}
+}
+
+func applyLaplacianFilter(b *core.BitMap, p params.Values) error {
+	edge, err := ParseEdgeMode(p.String("edge"))
+	if err != nil {
+		return err
+	}
+	neighbors := p.Int("neighbors")
+	if _, ok := laplacianKernels[neighbors]; !ok {
+		return fmt.Errorf("neighbors must be 4 or 8, got %d", neighbors)
+	}
+	ApplyLaplacianFilter(b, neighbors, p.Float("scale"), edge)
+	return nil
+}
+
+func applyCannyFilter(b *core.BitMap, p params.Values) error {
+	low, high := p.Float("low"), p.Float("high")
+	if low > high {
+		return fmt.Errorf("low threshold %g is above high threshold %g", low, high)
+	}
+	ApplyCannyFilter(b, p.Float("sigma"), low, high)
+	return nil
+}
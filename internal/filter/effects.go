@@ -0,0 +1,122 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"bitmap/internal/core"
+	"bitmap/internal/params"
+)
+
+// ApplyVignetteFilter darkens the corners. The distance from the center is
+// measured in half diagonals: darkening starts at radius, takes feather to
+// fade in and reaches strength (0..1) at its full extent.
+func ApplyVignetteFilter(b *core.BitMap, strength, radius, feather float64) {
+	planes := loadPlanes(b)
+	w, h := planes[0].w, planes[0].h
+	cx, cy := float64(w)/2, float64(h)/2
+	halfDiagonal := math.Hypot(cx, cy)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) / halfDiagonal
+			factor := 1 - strength*smoothstep(radius, radius+feather, d)
+			for _, p := range planes {
+				p.v[y*w+x] *= factor
+			}
+		}
+	}
+	storePlanes(b, planes)
+}
+
+// smoothstep rises smoothly from 0 at edge0 to 1 at edge1.
+func smoothstep(edge0, edge1, x float64) float64 {
+	if edge1 <= edge0 {
+		if x < edge0 {
+			return 0
+		}
+		return 1
+	}
+	t := clampFloat((x-edge0)/(edge1-edge0), 0, 1)
+	return t * t * (3 - 2*t)
+}
+
+// ApplyGrainFilter adds film grain: monochrome noise with a standard
+// deviation of amount levels, clumped by a blur of the given size and
+// strongest in the midtones, like silver grain on film.
+func ApplyGrainFilter(b *core.BitMap, amount, size float64, seed int64) {
+	planes := loadPlanes(b)
+	w, h := planes[0].w, planes[0].h
+	rng := rand.New(rand.NewSource(seed))
+
+	grain := newPlane(w, h)
+	for i := range grain.v {
+		grain.v[i] = rng.NormFloat64()
+	}
+	if size > 0 {
+		grain = grain.gaussianBlur(size, EdgeWrap)
+		// blurring lowers the spread, scale it back to one
+		var sum float64
+		for _, v := range grain.v {
+			sum += v * v
+		}
+		if std := math.Sqrt(sum / float64(len(grain.v))); std > 0 {
+			for i := range grain.v {
+				grain.v[i] /= std
+			}
+		}
+	}
+
+	l := luminance(planes)
+	for i, n := range grain.v {
+		t := l.v[i] / 255
+		weight := 4 * t * (1 - t)
+		for _, p := range planes {
+			p.v[i] += amount * n * weight
+		}
+	}
+	storePlanes(b, planes)
+}
+
+// ApplyGaussianNoiseFilter adds independent normal noise with the given
+// standard deviation to every channel.
+func ApplyGaussianNoiseFilter(b *core.BitMap, sigma float64, seed int64) {
+	rng := rand.New(rand.NewSource(seed))
+	planes := loadPlanes(b)
+	for i := range planes[0].v {
+		for _, p := range planes {
+			p.v[i] += rng.NormFloat64() * sigma
+		}
+	}
+	storePlanes(b, planes)
+}
+
+// ApplySaltPepperNoiseFilter turns the given share (0..1) of the pixels
+// black or white at random.
+func ApplySaltPepperNoiseFilter(b *core.BitMap, density float64, seed int64) {
+	rng := rand.New(rand.NewSource(seed))
+	Cycle(b, func(pixel *core.Pixel) {
+		if rng.Float64() >= density {
+			return
+		}
+		var v byte
+		if rng.Intn(2) == 1 {
+			v = 255
+		}
+		pixel.Red, pixel.Green, pixel.Blue = v, v, v
+	})
+}
+
+func applyNoiseFilter(b *core.BitMap, p params.Values) error {
+	seed := int64(p.Int("seed"))
+	switch p.String("type") {
+	case "gaussian":
+		ApplyGaussianNoiseFilter(b, p.Float("sigma"), seed)
+	case "saltpepper":
+		ApplySaltPepperNoiseFilter(b, p.Float("density"), seed)
+	default:
+		return fmt.Errorf("unknown noise type %q", p.String("type"))
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package filter
+
+import "bitmap/internal/core"
+
+// ApplyEqualizeFilter spreads the histogram so every brightness level is
+// used about equally. With perChannel each channel is equalized on its own,
+// which also stretches color casts; otherwise only the luma is equalized and
+// the colors keep their hue.
+func ApplyEqualizeFilter(b *core.BitMap, perChannel bool) {
+	planes := loadPlanes(b)
+	if perChannel {
+		for _, p := range planes {
+			equalize(p)
+		}
+		storePlanes(b, planes)
+		return
+	}
+
+	y, cb, cr := toYCbCr(planes)
+	equalize(y)
+	storePlanes(b, fromYCbCr(y, cb, cr))
+}
+
+// ApplyCLAHEFilter equalizes the luma in tiles of tileSize x tileSize
+// pixels with the histogram of each tile clipped at clipLimit times its
+// average bin, and blends the mappings of neighboring tiles bilinearly
+// (contrast limited adaptive histogram equalization).
+func ApplyCLAHEFilter(b *core.BitMap, tileSize int, clipLimit float64) {
+	planes := loadPlanes(b)
+	y, cb, cr := toYCbCr(planes)
+	clahe(y, tileSize, clipLimit)
+	storePlanes(b, fromYCbCr(y, cb, cr))
+}
+
+// equalize maps p through the cumulative distribution of its histogram.
+func equalize(p *plane) {
+	hist := histogram(p)
+	var counts [256]float64
+	for i, n := range hist {
+		counts[i] = float64(n)
+	}
+	table := equalizeTable(counts)
+	for i, v := range p.v {
+		p.v[i] = table[toByte(v)]
+	}
+}
+
+// equalizeTable returns the mapping of every level to its place in the
+// cumulative distribution of the histogram, spread over [0, 255].
+func equalizeTable(hist [256]float64) [256]float64 {
+	var total, first float64
+	for _, n := range hist {
+		total += n
+	}
+	// the darkest used level stays black
+	for _, n := range hist {
+		if n > 0 {
+			first = n
+			break
+		}
+	}
+
+	var table [256]float64
+	var cdf float64
+	for i, n := range hist {
+		cdf += n
+		if total > first {
+			table[i] = (cdf - first) / (total - first) * 255
+		} else {
+			table[i] = float64(i)
+		}
+		if table[i] < 0 {
+			table[i] = 0
+		}
+	}
+	return table
+}
+
+func clahe(p *plane, tileSize int, clipLimit float64) {
+	tilesX := (p.w + tileSize - 1) / tileSize
+	tilesY := (p.h + tileSize - 1) / tileSize
+	tables := make([][256]float64, tilesX*tilesY)
+
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			var hist [256]float64
+			x0, y0 := tx*tileSize, ty*tileSize
+			x1, y1 := min(x0+tileSize, p.w), min(y0+tileSize, p.h)
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					hist[toByte(p.at(x, y))]++
+				}
+			}
+			clipHistogram(&hist, clipLimit*float64((x1-x0)*(y1-y0))/256)
+			tables[ty*tilesX+tx] = cumulativeTable(hist)
+		}
+	}
+
+	out := newPlane(p.w, p.h)
+	for y := 0; y < p.h; y++ {
+		// position relative to the tile centers
+		fy := (float64(y)+0.5)/float64(tileSize) - 0.5
+		ty0 := clampInt(int(fy), 0, tilesY-1)
+		ty1 := min(ty0+1, tilesY-1)
+		wy := clampFloat(fy-float64(ty0), 0, 1)
+
+		for x := 0; x < p.w; x++ {
+			fx := (float64(x)+0.5)/float64(tileSize) - 0.5
+			tx0 := clampInt(int(fx), 0, tilesX-1)
+			tx1 := min(tx0+1, tilesX-1)
+			wx := clampFloat(fx-float64(tx0), 0, 1)
+
+			v := toByte(p.at(x, y))
+			top := tables[ty0*tilesX+tx0][v]*(1-wx) + tables[ty0*tilesX+tx1][v]*wx
+			bottom := tables[ty1*tilesX+tx0][v]*(1-wx) + tables[ty1*tilesX+tx1][v]*wx
+			out.set(x, y, top*(1-wy)+bottom*wy)
+		}
+	}
+	copy(p.v, out.v)
+}
+
+// clipHistogram cuts every bin at limit and spreads the excess evenly over
+// all bins, which limits how much the tile contrast can be amplified.
+func clipHistogram(hist *[256]float64, limit float64) {
+	if limit < 1 {
+		limit = 1
+	}
+	var excess float64
+	for i, n := range hist {
+		if n > limit {
+			excess += n - limit
+			hist[i] = limit
+		}
+	}
+	for i := range hist {
+		hist[i] += excess / 256
+	}
+}
+
+// cumulativeTable maps every level to its cumulative share of the
+// histogram, spread over [0, 255].
+func cumulativeTable(hist [256]float64) [256]float64 {
+	var total float64
+	for _, n := range hist {
+		total += n
+	}
+	var table [256]float64
+	var cdf float64
+	for i, n := range hist {
+		cdf += n
+		table[i] = cdf / total * 255
+	}
+	return table
+}
+
+// toYCbCr splits RGB planes into luma and two color difference planes
+// (ITU-R BT.601, full range).
+func toYCbCr(planes [3]*plane) (*plane, *plane, *plane) {
+	r, g, b := planes[0], planes[1], planes[2]
+	y, cb, cr := newPlane(r.w, r.h), newPlane(r.w, r.h), newPlane(r.w, r.h)
+	for i := range r.v {
+		y.v[i] = 0.299*r.v[i] + 0.587*g.v[i] + 0.114*b.v[i]
+		cb.v[i] = 128 - 0.168736*r.v[i] - 0.331264*g.v[i] + 0.5*b.v[i]
+		cr.v[i] = 128 + 0.5*r.v[i] - 0.418688*g.v[i] - 0.081312*b.v[i]
+	}
+	return y, cb, cr
+}
+
+func fromYCbCr(y, cb, cr *plane) [3]*plane {
+	r, g, b := newPlane(y.w, y.h), newPlane(y.w, y.h), newPlane(y.w, y.h)
+	for i := range y.v {
+		r.v[i] = y.v[i] + 1.402*(cr.v[i]-128)
+		g.v[i] = y.v[i] - 0.344136*(cb.v[i]-128) - 0.714136*(cr.v[i]-128)
+		b.v[i] = y.v[i] + 1.772*(cb.v[i]-128)
+	}
+	return [3]*plane{r, g, b}
+}
+
+func clampInt(v, low, high int) int {
+	return max(low, min(high, v))
+}
+
+func clampFloat(v, low, high float64) float64 {
+	return max(low, min(high, v))
+}
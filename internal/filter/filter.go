@@ -7,36 +7,496 @@ import (
 
 	"bitmap/config"
 	"bitmap/internal/core"
+	"bitmap/internal/palette"
+	"bitmap/internal/params"
 )
 
-var filterRegistry = map[string]func(*core.BitMap){
-	"blue":      ApplyBlueFilter,
-	"red":       ApplyRedFilter,
-	"green":     ApplyGreenFilter,
-	"grayscale": ApplyGrayscaleFilter,
-	"negative":  ApplyNegativeFilter,
-	"pixelate":  ApplyPixelateFilter,
-	"blur":      ApplyBlurFilter,
+// filterEntry describes a registered filter and the parameters it accepts
+// after its name, e.g. --filter=blur:radius=3.
+type filterEntry struct {
+	apply  func(*core.BitMap, params.Values) error
+	params []params.Spec
 }
 
-var pixelateCount = 0
+var filterRegistry = map[string]filterEntry{
+	"blue":  {apply: plain(ApplyBlueFilter)},
+	"red":   {apply: plain(ApplyRedFilter)},
+	"green": {apply: plain(ApplyGreenFilter)},
+	"autowhite": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			switch p.String("method") {
+			case "whitepatch":
+				ApplyWhitePatchFilter(b)
+			case "percentile":
+				ApplyPercentileStretchFilter(b, p.Float("percent"))
+			default:
+				ApplyGrayWorldFilter(b)
+			}
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "method", Kind: params.String, Default: "grayworld", Choices: []string{"grayworld", "whitepatch", "percentile"}},
+			{Name: "percent", Kind: params.Float, Default: "1", Min: 0, Max: 49},
+		},
+	},
+	"temperature": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyTemperatureFilter(b, p.Float("value"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "value", Kind: params.Float, Default: "20", Min: -100, Max: 100},
+		},
+	},
+	"tint": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyTintFilter(b, p.Float("value"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "value", Kind: params.Float, Default: "20", Min: -100, Max: 100},
+		},
+	},
+	"channelmix": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			return ApplyChannelMixFilter(b, p.Floats("matrix"))
+		},
+		params: []params.Spec{
+			{Name: "matrix", Kind: params.FloatList, Default: "1,0,0,0,1,0,0,0,1"},
+		},
+	},
+	"swap": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			return ApplySwapFilter(b, p.String("rgb"))
+		},
+		params: []params.Spec{
+			{Name: "rgb", Kind: params.String, Default: "bgr"},
+		},
+	},
+	"extract": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyExtractFilter(b, p.String("channel"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "channel", Kind: params.String, Default: "r", Choices: []string{"r", "g", "b"}},
+		},
+	},
+	"grayscale": {apply: plain(ApplyGrayscaleFilter)},
+	"negative":  {apply: plain(ApplyNegativeFilter)},
+	"brightness": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyBrightnessFilter(b, p.Float("value"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "value", Kind: params.Float, Default: "20", Min: -255, Max: 255},
+		},
+	},
+	"contrast": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyContrastFilter(b, p.Float("value"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "value", Kind: params.Float, Default: "20", Min: -100, Max: 100},
+		},
+	},
+	"gamma": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyGammaFilter(b, p.Float("value"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "value", Kind: params.Float, Default: "1.5", Min: 0.01, Max: 10},
+		},
+	},
+	"exposure": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyExposureFilter(b, p.Float("stops"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "stops", Kind: params.Float, Default: "1", Min: -10, Max: 10},
+		},
+	},
+	"levels": {
+		apply: applyLevelsFilter,
+		params: []params.Spec{
+			{Name: "black", Kind: params.Float, Default: "0", Min: 0, Max: 255},
+			{Name: "white", Kind: params.Float, Default: "255", Min: 0, Max: 255},
+			{Name: "gamma", Kind: params.Float, Default: "1", Min: 0.01, Max: 10},
+			{Name: "outblack", Kind: params.Float, Default: "0", Min: 0, Max: 255},
+			{Name: "outwhite", Kind: params.Float, Default: "255", Min: 0, Max: 255},
+			{Name: "channel", Kind: params.String, Default: "rgb", Choices: channelChoices},
+		},
+	},
+	"curves": {
+		apply: applyCurvesFilter,
+		params: []params.Spec{
+			{Name: "points", Kind: params.String},
+			{Name: "file", Kind: params.String},
+			{Name: "channel", Kind: params.String, Default: "rgb", Choices: channelChoices},
+		},
+	},
+	"hue": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyHueFilter(b, p.Float("degrees"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "degrees", Kind: params.Float, Default: "30", Min: -360, Max: 360},
+		},
+	},
+	"saturation": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplySaturationFilter(b, p.Float("amount"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "amount", Kind: params.Float, Default: "1.5", Min: 0, Max: 10},
+		},
+	},
+	"vibrance": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyVibranceFilter(b, p.Float("amount"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "amount", Kind: params.Float, Default: "0.5", Min: -1, Max: 1},
+		},
+	},
+	"colorize": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyColorizeFilter(b, p.Float("hue"), p.Float("saturation"), p.Float("amount"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "hue", Kind: params.Float, Default: "30", Min: -360, Max: 360},
+			{Name: "saturation", Kind: params.Float, Default: "0.5", Min: 0, Max: 1},
+			{Name: "amount", Kind: params.Float, Default: "1", Min: 0, Max: 1},
+		},
+	},
+	"sepia": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplySepiaFilter(b, p.Float("strength"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "strength", Kind: params.Float, Default: "1", Min: 0, Max: 1},
+		},
+	},
+	"duotone": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			colors := p.Colors("colors")
+			if len(colors) != 2 {
+				return fmt.Errorf("duotone needs exactly two colors, got %d", len(colors))
+			}
+			ApplyDuotoneFilter(b, colors[0], colors[1])
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "colors", Kind: params.ColorList, Default: "1b2a49,f2c14e", Min: 2},
+		},
+	},
+	"gradientmap": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyGradientMapFilter(b, p.Colors("colors"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "colors", Kind: params.ColorList, Default: "black,7b2cbf,ff9e00,white", Min: 2},
+		},
+	},
+	"threshold": {
+		apply: applyThresholdFilter,
+		params: []params.Spec{
+			{Name: "mode", Kind: params.String, Default: "fixed", Choices: []string{"fixed", "otsu", "mean", "gaussian"}},
+			{Name: "level", Kind: params.Float, Default: "128", Min: 0, Max: 255},
+			{Name: "window", Kind: params.Int, Default: "15", Min: 3, Max: 1001},
+			{Name: "offset", Kind: params.Float, Default: "5", Min: -255, Max: 255},
+		},
+	},
+	"dither": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			pal, err := palette.Parse(p.String("palette"))
+			if err != nil {
+				return err
+			}
+			return palette.Dither(b, pal, p.String("method"), p.Int("size"))
+		},
+		params: []params.Spec{
+			{Name: "method", Kind: params.String, Default: "floyd", Choices: palette.DitherMethods},
+			{Name: "palette", Kind: params.String, Default: "bw"},
+			{Name: "size", Kind: params.Int, Default: "4", Min: 2, Max: 8},
+		},
+	},
+	"posterize": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			pal, err := palette.Quantize(b, p.Int("colors"), p.String("method"))
+			if err != nil {
+				return err
+			}
+			return palette.Dither(b, pal, p.String("dither"), 4)
+		},
+		params: []params.Spec{
+			{Name: "colors", Kind: params.Int, Default: "8", Min: 2, Max: 256},
+			{Name: "method", Kind: params.String, Default: "mediancut", Choices: palette.QuantizeMethods},
+			{Name: "dither", Kind: params.String, Default: "none", Choices: palette.DitherMethods},
+		},
+	},
+	"equalize": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyEqualizeFilter(b, p.String("mode") == "rgb")
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "mode", Kind: params.String, Default: "luma", Choices: []string{"luma", "rgb"}},
+		},
+	},
+	"clahe": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyCLAHEFilter(b, p.Int("tile"), p.Float("clip"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "tile", Kind: params.Int, Default: "64", Min: 8, Max: 4096},
+			{Name: "clip", Kind: params.Float, Default: "2", Min: 1, Max: 256},
+		},
+	},
+	"median": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyMedianFilter(b, p.Int("radius"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "radius", Kind: params.Int, Default: "1", Min: 1, Max: 100},
+		},
+	},
+	"bilateral": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyBilateralFilter(b, p.Float("spatial"), p.Float("range"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "spatial", Kind: params.Float, Default: "3", Min: 0.5, Max: 50},
+			{Name: "range", Kind: params.Float, Default: "30", Min: 1, Max: 500},
+		},
+	},
+	"nlmeans": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyNLMeansFilter(b, p.Float("h"), p.Int("patch"), p.Int("search"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "h", Kind: params.Float, Default: "10", Min: 0.1, Max: 255},
+			{Name: "patch", Kind: params.Int, Default: "1", Min: 1, Max: 10},
+			{Name: "search", Kind: params.Int, Default: "5", Min: 1, Max: 30},
+		},
+	},
+	"sharpen": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplySharpenFilter(b, p.Float("amount"), p.Bool("adaptive"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "amount", Kind: params.Float, Default: "1", Min: 0, Max: 20},
+			{Name: "adaptive", Kind: params.Bool, Default: "true"},
+		},
+	},
+	"unsharp": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyUnsharpMaskFilter(b, p.Float("amount"), p.Float("radius"), p.Float("threshold"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "amount", Kind: params.Float, Default: "1", Min: 0, Max: 20},
+			{Name: "radius", Kind: params.Float, Default: "2", Min: 0.1, Max: 100},
+			{Name: "threshold", Kind: params.Float, Default: "0", Min: 0, Max: 255},
+		},
+	},
+	"erode":    {apply: morphologyFilter("erode"), params: morphologyParams},
+	"dilate":   {apply: morphologyFilter("dilate"), params: morphologyParams},
+	"open":     {apply: morphologyFilter("open"), params: morphologyParams},
+	"close":    {apply: morphologyFilter("close"), params: morphologyParams},
+	"gradient": {apply: morphologyFilter("gradient"), params: morphologyParams},
+	"tophat":   {apply: morphologyFilter("tophat"), params: morphologyParams},
+	"blackhat": {apply: morphologyFilter("blackhat"), params: morphologyParams},
+	"vignette": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyVignetteFilter(b, p.Float("strength"), p.Float("radius"), p.Float("feather"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "strength", Kind: params.Float, Default: "0.6", Min: 0, Max: 1},
+			{Name: "radius", Kind: params.Float, Default: "0.5", Min: 0, Max: 2},
+			{Name: "feather", Kind: params.Float, Default: "0.5", Min: 0, Max: 2},
+		},
+	},
+	"grain": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyGrainFilter(b, p.Float("amount"), p.Float("size"), int64(p.Int("seed")))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "amount", Kind: params.Float, Default: "12", Min: 0, Max: 255},
+			{Name: "size", Kind: params.Float, Default: "0.8", Min: 0, Max: 10},
+			{Name: "seed", Kind: params.Int, Default: "1"},
+		},
+	},
+	"noise": {
+		apply: applyNoiseFilter,
+		params: []params.Spec{
+			{Name: "type", Kind: params.String, Default: "gaussian", Choices: []string{"gaussian", "saltpepper"}},
+			{Name: "sigma", Kind: params.Float, Default: "20", Min: 0, Max: 255},
+			{Name: "density", Kind: params.Float, Default: "0.05", Min: 0, Max: 1},
+			{Name: "seed", Kind: params.Int, Default: "1"},
+		},
+	},
+	"chromakey": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyChromaKeyFilter(b, p.Color("key"), p.Float("tolerance"), p.Float("softness"), p.Color("background"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "key", Kind: params.Color, Default: "00ff00"},
+			{Name: "tolerance", Kind: params.Float, Default: "40", Min: 0, Max: 255},
+			{Name: "softness", Kind: params.Float, Default: "20", Min: 0, Max: 255},
+			{Name: "background", Kind: params.Color, Default: "ffffff"},
+		},
+	},
+	"replacecolor": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyReplaceColorFilter(b, p.Color("from"), p.Color("to"), p.Float("tolerance"), p.Float("softness"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "from", Kind: params.Color, Default: "ff0000"},
+			{Name: "to", Kind: params.Color, Default: "0000ff"},
+			{Name: "tolerance", Kind: params.Float, Default: "60", Min: 0, Max: 442},
+			{Name: "softness", Kind: params.Float, Default: "40", Min: 0, Max: 442},
+		},
+	},
+	"lut": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			if p.String("file") == "" {
+				return fmt.Errorf("a .cube file is required")
+			}
+			l, err := LoadCubeLUT(p.String("file"))
+			if err != nil {
+				return err
+			}
+			ApplyCubeLUTFilter(b, l, p.String("interp") == "tetrahedral")
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "file", Kind: params.String},
+			{Name: "interp", Kind: params.String, Default: "trilinear", Choices: []string{"trilinear", "tetrahedral"}},
+		},
+	},
+	"pixelate": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			ApplyPixelateFilter(b, p.Int("size"))
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "size", Kind: params.Int, Default: "20", Min: 1, Max: 4096},
+		},
+	},
+	"blur": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			edge, err := ParseEdgeMode(p.String("edge"))
+			if err != nil {
+				return err
+			}
+			ApplyBlurFilter(b, p.Int("radius"), edge)
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "radius", Kind: params.Int, Default: "10", Min: 1, Max: 1024},
+			{Name: "edge", Kind: params.String, Default: "clamp", Choices: edgeChoices},
+		},
+	},
+	"gaussian": {
+		apply: func(b *core.BitMap, p params.Values) error {
+			edge, err := ParseEdgeMode(p.String("edge"))
+			if err != nil {
+				return err
+			}
+			ApplyGaussianBlurFilter(b, p.Float("sigma"), edge)
+			return nil
+		},
+		params: []params.Spec{
+			{Name: "sigma", Kind: params.Float, Default: "2", Min: 0.1, Max: 500},
+			{Name: "edge", Kind: params.String, Default: "clamp", Choices: edgeChoices},
+		},
+	},
+	"sobel":   {apply: gradientFilter("sobel"), params: gradientParams},
+	"prewitt": {apply: gradientFilter("prewitt"), params: gradientParams},
+	"scharr":  {apply: gradientFilter("scharr"), params: gradientParams},
+	"laplacian": {
+		apply: applyLaplacianFilter,
+		params: []params.Spec{
+			{Name: "neighbors", Kind: params.Int, Default: "4", Min: 4, Max: 8},
+			{Name: "scale", Kind: params.Float, Default: "1", Min: 0.001, Max: 1000},
+			{Name: "edge", Kind: params.String, Default: "clamp", Choices: edgeChoices},
+		},
+	},
+	"canny": {
+		apply: applyCannyFilter,
+		params: []params.Spec{
+			{Name: "sigma", Kind: params.Float, Default: "1.4", Min: 0, Max: 100},
+			{Name: "low", Kind: params.Float, Default: "40", Min: 0, Max: 2000},
+			{Name: "high", Kind: params.Float, Default: "100", Min: 0, Max: 2000},
+		},
+	},
+	"kernel": {
+		apply: applyKernelFilter,
+		params: []params.Spec{
+			{Name: "name", Kind: params.String, Choices: kernelChoices},
+			{Name: "file", Kind: params.String},
+			{Name: "radius", Kind: params.Int, Default: "1", Min: 1, Max: 64},
+			{Name: "edge", Kind: params.String, Default: "clamp", Choices: edgeChoices},
+			{Name: "bias", Kind: params.Float, Default: "0"},
+			{Name: "normalize", Kind: params.Bool, Default: "true"},
+		},
+	},
+}
 
 func HandleFilter(b *core.BitMap) {
 	if len(config.FilterFlag) == 0 {
 		return
 	}
 
-	filter := config.FilterFlag[0]
-	filterFunc, exists := filterRegistry[strings.ToLower(filter)]
+	name, raw := params.Split(config.FilterFlag[0])
+	name = strings.ToLower(name)
+	entry, exists := filterRegistry[name]
 	if !exists {
-		_, _ = fmt.Fprintln(os.Stderr, "ERROR: Filter not found")
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: Filter not found: %q\n", name)
 		os.Exit(1)
 	}
 
-	filterFunc(b)
+	values, err := params.Parse(fmt.Sprintf("filter %q", name), raw, entry.params)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err = entry.apply(b, values); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: filter %q: %v\n", name, err)
+		os.Exit(1)
+	}
 	config.FilterFlag = config.FilterFlag[1:]
 }
 
+// plain adapts a filter without parameters to the registry signature.
+func plain(filter func(*core.BitMap)) func(*core.BitMap, params.Values) error {
+	return func(b *core.BitMap, _ params.Values) error {
+		filter(b)
+		return nil
+	}
+}
+
 // Cycle Helper function to transform pixels
 func Cycle(b *core.BitMap, cycleFunc func(pixel *core.Pixel)) {
 	pixels := b.GetPixels()
@@ -88,13 +548,11 @@ func ApplyNegativeFilter(b *core.BitMap) {
 	})
 }
 
-func ApplyPixelateFilter(b *core.BitMap) {
+func ApplyPixelateFilter(b *core.BitMap, blockSize int) {
 	// Get the 2D array of pixels from the image
 	pixels := b.GetPixels()
 	// Get the height and width of the image
 	height, width := b.GetDimensions()
-	// Define the block size for pixelation. 20x20 pixels
-	blockSize := 20 + pixelateCount*10
 	// Loop through the image with a step equal to the block size
 	for x := 0; x < int(height); x += blockSize {
 		for y := 0; y < int(width); y += blockSize {
@@ -124,40 +582,17 @@ func ApplyPixelateFilter(b *core.BitMap) {
 			}
 		}
 	}
-	pixelateCount++
 }
 
-func ApplyBlurFilter(b *core.BitMap) {
-	pixel := b.GetPixels()
-	h, w := b.GetDimensions()
-	// Loop to find all indexes
-	for y := int32(0); y < h; y++ {
-		for x := int32(0); x < w; x++ {
-			var count int
-			var avgRed, avgGreen, avgBlue int
-			// Loop to find all neighbors
-			for i := -10; i <= 10; i++ {
-				for j := -10; j <= 10; j++ {
-					nx := x + int32(i)
-					ny := y + int32(j)
-					// To check pixels without of range of array
-					if nx >= 0 && nx < w && ny >= 0 && ny < h {
-						avgRed += int(pixel[ny][nx].Red)
-						avgGreen += int(pixel[ny][nx].Green)
-						avgBlue += int(pixel[ny][nx].Blue)
-						// Count of neighbors
-						count++
-					}
-				}
-			}
-			// Find average value for pixel
-			avgRed /= count
-			avgGreen /= count
-			avgBlue /= count
-			// Set new pixels
-			pixel[y][x].Red = byte(avgRed)
-			pixel[y][x].Green = byte(avgGreen)
-			pixel[y][x].Blue = byte(avgBlue)
-		}
+// ApplyBlurFilter replaces every pixel with the average of the
+// (2*radius+1)x(2*radius+1) window around it. The window is summed as two
+// separable passes, so large radii cost the same as small ones. Near the
+// border the window is filled in by the edge mode, so every average has
+// the same number of values.
+func ApplyBlurFilter(b *core.BitMap, radius int, edge EdgeMode) {
+	planes := loadPlanes(b)
+	for i, p := range planes {
+		planes[i] = p.boxBlur(radius, edge)
 	}
+	storePlanes(b, planes)
 }
@@ -0,0 +1,158 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Kernel is a convolution matrix with odd width and height, stored row by
+// row from the top.
+type Kernel struct {
+	Width  int
+	Height int
+	Values []float64
+}
+
+var namedKernels = map[string]func(radius int) Kernel{
+	"box":      boxKernel,
+	"gaussian": gaussianKernel,
+	"sharpen": func(int) Kernel {
+		return Kernel{Width: 3, Height: 3, Values: []float64{
+			0, -1, 0,
+			-1, 5, -1,
+			0, -1, 0,
+		}}
+	},
+	"emboss": func(int) Kernel {
+		return Kernel{Width: 3, Height: 3, Values: []float64{
+			-2, -1, 0,
+			-1, 1, 1,
+			0, 1, 2,
+		}}
+	},
+	"outline": func(int) Kernel {
+		return Kernel{Width: 3, Height: 3, Values: []float64{
+			-1, -1, -1,
+			-1, 8, -1,
+			-1, -1, -1,
+		}}
+	},
+}
+
+// kernelChoices lists the names accepted by NamedKernel.
+var kernelChoices = []string{"box", "gaussian", "sharpen", "emboss", "outline"}
+
+// NamedKernel returns one of the built-in kernels. The radius is used by the
+// box and gaussian kernels, the others are always 3x3.
+func NamedKernel(name string, radius int) Kernel {
+	return namedKernels[name](radius)
+}
+
+func boxKernel(radius int) Kernel {
+	size := 2*radius + 1
+	k := Kernel{Width: size, Height: size, Values: make([]float64, size*size)}
+	for i := range k.Values {
+		k.Values[i] = 1 / float64(size*size)
+	}
+	return k
+}
+
+func gaussianKernel(radius int) Kernel {
+	weights := gaussianWeights(gaussianSigma(radius), radius)
+	size := len(weights)
+	k := Kernel{Width: size, Height: size, Values: make([]float64, size*size)}
+	for y, wy := range weights {
+		for x, wx := range weights {
+			k.Values[y*size+x] = wy * wx
+		}
+	}
+	return k
+}
+
+// gaussianSigma picks the standard deviation that fits a window of the given
+// radius, the same rule OpenCV uses for a kernel size without a sigma.
+func gaussianSigma(radius int) float64 {
+	return 0.3*float64(radius-1) + 0.8
+}
+
+// gaussianWeights returns a normalized 1D Gaussian of length 2*radius+1.
+func gaussianWeights(sigma float64, radius int) []float64 {
+	weights := make([]float64, 2*radius+1)
+	var sum float64
+	for i := range weights {
+		d := float64(i - radius)
+		weights[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += weights[i]
+	}
+	for i := range weights {
+		weights[i] /= sum
+	}
+	return weights
+}
+
+// Normalized returns a copy of k whose weights add up to one. Kernels that
+// sum to zero, like edge detectors, are returned unchanged.
+func (k Kernel) Normalized() Kernel {
+	var sum float64
+	for _, v := range k.Values {
+		sum += v
+	}
+	if math.Abs(sum) < 1e-9 {
+		return k
+	}
+	n := Kernel{Width: k.Width, Height: k.Height, Values: make([]float64, len(k.Values))}
+	for i, v := range k.Values {
+		n.Values[i] = v / sum
+	}
+	return n
+}
+
+// LoadKernel reads a custom kernel from a text file with one matrix row per
+// line. Values are separated by spaces or commas, empty lines and lines
+// starting with '#' are ignored.
+func LoadKernel(path string) (Kernel, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Kernel{}, err
+	}
+	defer file.Close()
+
+	var k Kernel
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if k.Height > 0 && len(fields) != k.Width {
+			return Kernel{}, fmt.Errorf("%s:%d: expected %d values, got %d", path, line, k.Width, len(fields))
+		}
+		for _, f := range fields {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return Kernel{}, fmt.Errorf("%s:%d: invalid value %q", path, line, f)
+			}
+			k.Values = append(k.Values, v)
+		}
+		k.Width = len(fields)
+		k.Height++
+	}
+	if err = scanner.Err(); err != nil {
+		return Kernel{}, err
+	}
+
+	if k.Height == 0 {
+		return Kernel{}, fmt.Errorf("%s: kernel is empty", path)
+	}
+	if k.Width%2 == 0 || k.Height%2 == 0 {
+		return Kernel{}, fmt.Errorf("%s: kernel must have an odd number of rows and columns, got %dx%d", path, k.Width, k.Height)
+	}
+	return k, nil
+}
@@ -0,0 +1,121 @@
+package filter
+
+import (
+	"bitmap/internal/core"
+	"bitmap/internal/params"
+)
+
+// morphologyParams are shared by all morphological filters.
+var morphologyParams = []params.Spec{
+	{Name: "shape", Kind: params.String, Default: "square", Choices: []string{"square", "cross", "disk"}},
+	{Name: "radius", Kind: params.Int, Default: "1", Min: 1, Max: 100},
+	{Name: "mode", Kind: params.String, Default: "rgb", Choices: []string{"rgb", "gray"}},
+}
+
+// point is an offset inside a structuring element.
+type point struct {
+	x, y int
+}
+
+// structuringElement returns the offsets covered by a square, cross or disk
+// of the given radius around the origin.
+func structuringElement(shape string, radius int) []point {
+	var element []point
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			switch shape {
+			case "cross":
+				if dx != 0 && dy != 0 {
+					continue
+				}
+			case "disk":
+				if dx*dx+dy*dy > radius*radius {
+					continue
+				}
+			}
+			element = append(element, point{dx, dy})
+		}
+	}
+	return element
+}
+
+// morph replaces every value with the minimum (erode) or maximum (dilate)
+// under the structuring element.
+func (p *plane) morph(element []point, radius int, dilate bool) *plane {
+	out := newPlane(p.w, p.h)
+	if p.w == 0 || p.h == 0 {
+		return out
+	}
+	xs := EdgeClamp.edgeTable(p.w, radius)
+	ys := EdgeClamp.edgeTable(p.h, radius)
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			best := p.v[y*p.w+x]
+			for _, o := range element {
+				v := p.v[ys[y+o.y+radius]*p.w+xs[x+o.x+radius]]
+				if dilate && v > best || !dilate && v < best {
+					best = v
+				}
+			}
+			out.v[y*p.w+x] = best
+		}
+	}
+	return out
+}
+
+// morphology runs the named operation on p.
+func (p *plane) morphology(op string, element []point, radius int) *plane {
+	erode := func(q *plane) *plane { return q.morph(element, radius, false) }
+	dilate := func(q *plane) *plane { return q.morph(element, radius, true) }
+
+	switch op {
+	case "erode":
+		return erode(p)
+	case "dilate":
+		return dilate(p)
+	case "open":
+		return dilate(erode(p))
+	case "close":
+		return erode(dilate(p))
+	case "gradient":
+		return difference(dilate(p), erode(p))
+	case "tophat":
+		return difference(p, dilate(erode(p)))
+	default: // blackhat
+		return difference(erode(dilate(p)), p)
+	}
+}
+
+func difference(a, b *plane) *plane {
+	out := newPlane(a.w, a.h)
+	for i := range out.v {
+		out.v[i] = a.v[i] - b.v[i]
+	}
+	return out
+}
+
+// ApplyMorphologyFilter runs a morphological operation (erode, dilate,
+// open, close, gradient, tophat or blackhat) with a square, cross or disk
+// structuring element. With gray set it works on the luminance and writes a
+// gray image, otherwise on every channel separately.
+func ApplyMorphologyFilter(b *core.BitMap, op, shape string, radius int, gray bool) {
+	element := structuringElement(shape, radius)
+	planes := loadPlanes(b)
+	if gray {
+		l := luminance(planes).morphology(op, element, radius)
+		storePlanes(b, [3]*plane{l, l, l})
+		return
+	}
+	for i, p := range planes {
+		planes[i] = p.morphology(op, element, radius)
+	}
+	storePlanes(b, planes)
+}
+
+// morphologyFilter builds the registry function of a morphological operation.
+func morphologyFilter(op string) func(*core.BitMap, params.Values) error {
+	return func(b *core.BitMap, p params.Values) error {
+		ApplyMorphologyFilter(b, op, p.String("shape"), p.Int("radius"), p.String("mode") == "gray")
+		return nil
+	}
+}
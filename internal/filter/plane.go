@@ -0,0 +1,90 @@
+package filter
+
+import (
+	"math"
+
+	"bitmap/internal/core"
+)
+
+// plane is a single image channel stored as floats in row-major order with
+// row 0 at the top of the picture. Filters that must not read pixels they
+// have already changed work on planes and write the result back at the end.
+type plane struct {
+	w, h int
+	v    []float64
+}
+
+func newPlane(w, h int) *plane {
+	return &plane{w: w, h: h, v: make([]float64, w*h)}
+}
+
+func (p *plane) at(x, y int) float64 {
+	return p.v[y*p.w+x]
+}
+
+func (p *plane) set(x, y int, v float64) {
+	p.v[y*p.w+x] = v
+}
+
+func (p *plane) clone() *plane {
+	c := newPlane(p.w, p.h)
+	copy(c.v, p.v)
+	return c
+}
+
+// loadPlanes copies the red, green and blue channels of b into planes.
+func loadPlanes(b *core.BitMap) [3]*plane {
+	pixels := b.GetPixels()
+	h := len(pixels)
+	w := 0
+	if h > 0 {
+		w = len(pixels[0])
+	}
+
+	planes := [3]*plane{newPlane(w, h), newPlane(w, h), newPlane(w, h)}
+	for y := 0; y < h; y++ {
+		// BMP rows are stored bottom-up
+		row := pixels[h-1-y]
+		for x := 0; x < w; x++ {
+			planes[0].set(x, y, float64(row[x].Red))
+			planes[1].set(x, y, float64(row[x].Green))
+			planes[2].set(x, y, float64(row[x].Blue))
+		}
+	}
+	return planes
+}
+
+// storePlanes writes the planes back into b, rounding and clamping every
+// value to a byte.
+func storePlanes(b *core.BitMap, planes [3]*plane) {
+	pixels := b.GetPixels()
+	h := len(pixels)
+	for y := 0; y < h; y++ {
+		row := pixels[h-1-y]
+		for x := range row {
+			row[x].Red = toByte(planes[0].at(x, y))
+			row[x].Green = toByte(planes[1].at(x, y))
+			row[x].Blue = toByte(planes[2].at(x, y))
+		}
+	}
+}
+
+func toByte(v float64) byte {
+	if v <= 0 || math.IsNaN(v) {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return byte(v + 0.5)
+}
+
+// luminance returns the perceived brightness of the planes, weighted the
+// same way as the grayscale filter.
+func luminance(planes [3]*plane) *plane {
+	l := newPlane(planes[0].w, planes[0].h)
+	for i := range l.v {
+		l.v[i] = 0.3*planes[0].v[i] + 0.59*planes[1].v[i] + 0.11*planes[2].v[i]
+	}
+	return l
+}
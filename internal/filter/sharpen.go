@@ -0,0 +1,66 @@
+package filter
+
+import (
+	"math"
+
+	"bitmap/internal/core"
+)
+
+// adaptiveSoftness is the gradient magnitude at which adaptive sharpening
+// reaches half of its amount. Flat areas with weaker gradients, where
+// sharpening mostly amplifies noise, get less.
+const adaptiveSoftness = 32
+
+// ApplyUnsharpMaskFilter sharpens by adding back amount times the
+// difference between the image and a Gaussian blur of it with the given
+// radius (sigma). Differences up to threshold are left alone, so fine noise
+// and smooth gradients are not sharpened.
+func ApplyUnsharpMaskFilter(b *core.BitMap, amount, radius, threshold float64) {
+	planes := loadPlanes(b)
+	for i, p := range planes {
+		blurred := p.gaussianBlur(radius, EdgeMirror)
+		out := newPlane(p.w, p.h)
+		for j, v := range p.v {
+			detail := v - blurred.v[j]
+			if math.Abs(detail) <= threshold {
+				out.v[j] = v
+				continue
+			}
+			out.v[j] = v + amount*detail
+		}
+		planes[i] = out
+	}
+	storePlanes(b, planes)
+}
+
+// ApplySharpenFilter boosts fine detail by amount. With adaptive set the
+// boost follows the local gradient strength: edges get the full amount
+// while flat, noisy areas are left mostly unchanged.
+func ApplySharpenFilter(b *core.BitMap, amount float64, adaptive bool) {
+	planes := loadPlanes(b)
+
+	var weights *plane
+	if adaptive {
+		gx, gy := luminance(planes).gradient(gradientOperators["sobel"], EdgeClamp)
+		weights = newPlane(gx.w, gx.h)
+		for i := range weights.v {
+			m := math.Hypot(gx.v[i], gy.v[i]) / 4
+			weights.v[i] = m / (m + adaptiveSoftness)
+		}
+		weights = weights.boxBlur(1, EdgeClamp)
+	}
+
+	for i, p := range planes {
+		blurred := p.gaussianBlur(1, EdgeMirror)
+		out := newPlane(p.w, p.h)
+		for j, v := range p.v {
+			a := amount
+			if weights != nil {
+				a *= weights.v[j]
+			}
+			out.v[j] = v + a*(v-blurred.v[j])
+		}
+		planes[i] = out
+	}
+	storePlanes(b, planes)
+}
@@ -0,0 +1,110 @@
+package filter
+
+import (
+	"fmt"
+
+	"bitmap/internal/core"
+	"bitmap/internal/params"
+)
+
+// ApplyThresholdFilter turns the image black and white: pixels whose
+// luminance is above level become white, the others black.
+func ApplyThresholdFilter(b *core.BitMap, level float64) {
+	l := luminance(loadPlanes(b))
+	binarize(b, l, func(int) float64 { return level })
+}
+
+// ApplyOtsuThresholdFilter thresholds the image at the level that best
+// separates the luminance histogram into two classes and returns it.
+func ApplyOtsuThresholdFilter(b *core.BitMap) int {
+	l := luminance(loadPlanes(b))
+	// threshold the same rounded values the histogram counts, so every
+	// pixel lands in the class Otsu put it in
+	for i, v := range l.v {
+		l.v[i] = float64(toByte(v))
+	}
+	level := OtsuThreshold(histogram(l))
+	binarize(b, l, func(int) float64 { return float64(level) })
+	return level
+}
+
+// ApplyAdaptiveThresholdFilter compares every pixel with the mean of the
+// window x window area around it, weighted evenly or with a Gaussian, minus
+// offset. It keeps text readable under uneven lighting where a single
+// global level fails.
+func ApplyAdaptiveThresholdFilter(b *core.BitMap, window int, gaussian bool, offset float64) {
+	l := luminance(loadPlanes(b))
+	var local *plane
+	if gaussian {
+		local = l.gaussianBlur(gaussianSigma(window/2), EdgeMirror)
+	} else {
+		local = l.boxBlur(window/2, EdgeMirror)
+	}
+	binarize(b, l, func(i int) float64 { return local.v[i] - offset })
+}
+
+// binarize writes white where l is above the level of that pixel and black
+// elsewhere.
+func binarize(b *core.BitMap, l *plane, level func(i int) float64) {
+	out := newPlane(l.w, l.h)
+	for i, v := range l.v {
+		if v > level(i) {
+			out.v[i] = 255
+		}
+	}
+	storePlanes(b, [3]*plane{out, out, out})
+}
+
+func histogram(l *plane) [256]int {
+	var hist [256]int
+	for _, v := range l.v {
+		hist[toByte(v)]++
+	}
+	return hist
+}
+
+// OtsuThreshold returns the level that maximizes the variance between the
+// values at or below it and the values above it.
+func OtsuThreshold(hist [256]int) int {
+	var total, sum float64
+	for i, n := range hist {
+		total += float64(n)
+		sum += float64(i * n)
+	}
+
+	var best, weightBelow, sumBelow, bestVariance float64
+	for i, n := range hist {
+		weightBelow += float64(n)
+		if weightBelow == 0 {
+			continue
+		}
+		weightAbove := total - weightBelow
+		if weightAbove == 0 {
+			break
+		}
+		sumBelow += float64(i * n)
+		meanBelow := sumBelow / weightBelow
+		meanAbove := (sum - sumBelow) / weightAbove
+		variance := weightBelow * weightAbove * (meanBelow - meanAbove) * (meanBelow - meanAbove)
+		if variance > bestVariance {
+			best, bestVariance = float64(i), variance
+		}
+	}
+	return int(best)
+}
+
+func applyThresholdFilter(b *core.BitMap, p params.Values) error {
+	switch p.String("mode") {
+	case "otsu":
+		ApplyOtsuThresholdFilter(b)
+	case "mean", "gaussian":
+		window := p.Int("window")
+		if window%2 == 0 {
+			return fmt.Errorf("window must be odd, got %d", window)
+		}
+		ApplyAdaptiveThresholdFilter(b, window, p.String("mode") == "gaussian", p.Float("offset"))
+	default:
+		ApplyThresholdFilter(b, p.Float("level"))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package filter
+
+import (
+	"math"
+
+	"bitmap/internal/core"
+)
+
+// lut maps every byte value of a channel to its adjusted value.
+type lut [256]byte
+
+// newLUT builds a lookup table from a function over [0, 255].
+func newLUT(f func(v float64) float64) *lut {
+	var l lut
+	for i := range l {
+		l[i] = toByte(f(float64(i)))
+	}
+	return &l
+}
+
+// applyLUTs maps the red, green and blue channels through their tables.
+// A nil table leaves its channel unchanged.
+func applyLUTs(b *core.BitMap, red, green, blue *lut) {
+	Cycle(b, func(pixel *core.Pixel) {
+		if red != nil {
+			pixel.Red = red[pixel.Red]
+		}
+		if green != nil {
+			pixel.Green = green[pixel.Green]
+		}
+		if blue != nil {
+			pixel.Blue = blue[pixel.Blue]
+		}
+	})
+}
+
+// applyLUT maps all three channels through the same table.
+func applyLUT(b *core.BitMap, l *lut) {
+	applyLUTs(b, l, l, l)
+}
+
+// ApplyBrightnessFilter adds value (-255..255) to every channel.
+func ApplyBrightnessFilter(b *core.BitMap, value float64) {
+	applyLUT(b, newLUT(func(v float64) float64 {
+		return v + value
+	}))
+}
+
+// ApplyContrastFilter stretches (value > 0) or flattens (value < 0) the
+// channels around mid-gray. The value goes from -100 (flat gray) to 100.
+func ApplyContrastFilter(b *core.BitMap, value float64) {
+	factor := (100 + value) / 100
+	factor *= factor
+	applyLUT(b, newLUT(func(v float64) float64 {
+		return (v-127.5)*factor + 127.5
+	}))
+}
+
+// ApplyGammaFilter applies a power curve. Gamma above 1 brightens the
+// midtones, below 1 darkens them.
+func ApplyGammaFilter(b *core.BitMap, gamma float64) {
+	applyLUT(b, newLUT(func(v float64) float64 {
+		return 255 * math.Pow(v/255, 1/gamma)
+	}))
+}
+
+// ApplyExposureFilter scales linear light by 2^stops, like changing the
+// exposure of the camera. Values are converted from sRGB to linear light and
+// back so a stop doubles the amount of light rather than the byte value.
+func ApplyExposureFilter(b *core.BitMap, stops float64) {
+	gain := math.Pow(2, stops)
+	applyLUT(b, newLUT(func(v float64) float64 {
+		return 255 * linearToSRGB(srgbToLinear(v/255)*gain)
+	}))
+}
+
+func srgbToLinear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(c float64) float64 {
+	if c >= 1 {
+		return 1
+	}
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return 1.055*math.Pow(c, 1/2.4) - 0.055
+}
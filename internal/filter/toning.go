@@ -0,0 +1,53 @@
+package filter
+
+import "bitmap/internal/core"
+
+// ApplySepiaFilter tones the image brown like an old photograph. The
+// strength (0..1) blends the toned color with the original.
+func ApplySepiaFilter(b *core.BitMap, strength float64) {
+	Cycle(b, func(pixel *core.Pixel) {
+		r, g, bl := float64(pixel.Red), float64(pixel.Green), float64(pixel.Blue)
+		pixel.Red = mix(pixel.Red, toByte(0.393*r+0.769*g+0.189*bl), strength)
+		pixel.Green = mix(pixel.Green, toByte(0.349*r+0.686*g+0.168*bl), strength)
+		pixel.Blue = mix(pixel.Blue, toByte(0.272*r+0.534*g+0.131*bl), strength)
+	})
+}
+
+// ApplyGradientMapFilter replaces every pixel with a color picked from a
+// gradient by its luminance: the first color for black, the last one for
+// white and evenly spaced stops in between.
+func ApplyGradientMapFilter(b *core.BitMap, colors []core.Pixel) {
+	var table [256]core.Pixel
+	for i := range table {
+		table[i] = gradientAt(colors, float64(i)/255)
+	}
+	Cycle(b, func(pixel *core.Pixel) {
+		l := 0.3*float64(pixel.Red) + 0.59*float64(pixel.Green) + 0.11*float64(pixel.Blue)
+		*pixel = table[toByte(l)]
+	})
+}
+
+// ApplyDuotoneFilter maps the shadows to one color and the highlights to
+// another.
+func ApplyDuotoneFilter(b *core.BitMap, shadow, highlight core.Pixel) {
+	ApplyGradientMapFilter(b, []core.Pixel{shadow, highlight})
+}
+
+// gradientAt returns the color at t in [0, 1] of a gradient through colors.
+func gradientAt(colors []core.Pixel, t float64) core.Pixel {
+	if len(colors) == 1 {
+		return colors[0]
+	}
+	pos := t * float64(len(colors)-1)
+	i := int(pos)
+	if i >= len(colors)-1 {
+		return colors[len(colors)-1]
+	}
+	f := pos - float64(i)
+	from, to := colors[i], colors[i+1]
+	return core.Pixel{
+		Red:   mix(from.Red, to.Red, f),
+		Green: mix(from.Green, to.Green, f),
+		Blue:  mix(from.Blue, to.Blue, f),
+	}
+}
@@ -0,0 +1,118 @@
+package filter
+
+import (
+	"math"
+
+	"bitmap/internal/core"
+)
+
+// tempStrength is the largest change of a channel gain made by the
+// temperature and tint filters at a value of ±100.
+const tempStrength = 0.3
+
+// ApplyGrayWorldFilter balances the channels so that their averages are
+// equal, assuming the scene is gray on average.
+func ApplyGrayWorldFilter(b *core.BitMap) {
+	var sums [3]float64
+	var count float64
+	Cycle(b, func(pixel *core.Pixel) {
+		sums[0] += float64(pixel.Red)
+		sums[1] += float64(pixel.Green)
+		sums[2] += float64(pixel.Blue)
+		count++
+	})
+	if count == 0 {
+		return
+	}
+	gray := (sums[0] + sums[1] + sums[2]) / 3
+	applyGains(b, gray/sums[0], gray/sums[1], gray/sums[2])
+}
+
+// ApplyWhitePatchFilter scales every channel so that its brightest value
+// becomes white, assuming the brightest spot of the scene is white (max-RGB).
+func ApplyWhitePatchFilter(b *core.BitMap) {
+	var highs [3]float64
+	Cycle(b, func(pixel *core.Pixel) {
+		highs[0] = math.Max(highs[0], float64(pixel.Red))
+		highs[1] = math.Max(highs[1], float64(pixel.Green))
+		highs[2] = math.Max(highs[2], float64(pixel.Blue))
+	})
+	applyGains(b, 255/highs[0], 255/highs[1], 255/highs[2])
+}
+
+// ApplyPercentileStretchFilter stretches every channel so that the given
+// percent of its darkest values become black and the same percent of its
+// brightest values white. Clipping a few outliers makes it more robust than
+// the white patch method.
+func ApplyPercentileStretchFilter(b *core.BitMap, percent float64) {
+	var hists [3][256]int
+	var total int
+	Cycle(b, func(pixel *core.Pixel) {
+		hists[0][pixel.Red]++
+		hists[1][pixel.Green]++
+		hists[2][pixel.Blue]++
+		total++
+	})
+
+	var luts [3]*lut
+	for ch, hist := range hists {
+		low, high := percentiles(hist, total, percent)
+		if high <= low {
+			continue
+		}
+		luts[ch] = newLUT(func(v float64) float64 {
+			return (v - low) / (high - low) * 255
+		})
+	}
+	applyLUTs(b, luts[0], luts[1], luts[2])
+}
+
+// percentiles returns the darkest and the brightest level that remain when
+// the given percent of the values is cut off at each end. Both ends are
+// scanned from the outside, so percent 0 gives the channel minimum and
+// maximum.
+func percentiles(hist [256]int, total int, percent float64) (float64, float64) {
+	limit := float64(total) * percent / 100
+	low, high := 0, 255
+	for seen := 0; low < 255; low++ {
+		seen += hist[low]
+		if float64(seen) > limit {
+			break
+		}
+	}
+	for seen := 0; high > 0; high-- {
+		seen += hist[high]
+		if float64(seen) > limit {
+			break
+		}
+	}
+	return float64(low), float64(high)
+}
+
+// ApplyTemperatureFilter warms (value > 0) or cools (value < 0) the image
+// by trading red against blue. The value goes from -100 to 100.
+func ApplyTemperatureFilter(b *core.BitMap, value float64) {
+	t := value / 100 * tempStrength
+	applyGains(b, 1+t, 1, 1-t)
+}
+
+// ApplyTintFilter shifts the image towards magenta (value > 0) or green
+// (value < 0). The value goes from -100 to 100.
+func ApplyTintFilter(b *core.BitMap, value float64) {
+	applyGains(b, 1, 1-value/100*tempStrength, 1)
+}
+
+// applyGains multiplies every channel by its gain through lookup tables.
+// Gains of channels without any signal are ignored.
+func applyGains(b *core.BitMap, red, green, blue float64) {
+	var luts [3]*lut
+	for ch, gain := range [3]float64{red, green, blue} {
+		if math.IsInf(gain, 0) || math.IsNaN(gain) || gain == 1 {
+			continue
+		}
+		luts[ch] = newLUT(func(v float64) float64 {
+			return v * gain
+		})
+	}
+	applyLUTs(b, luts[0], luts[1], luts[2])
+}
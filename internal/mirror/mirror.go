@@ -3,6 +3,7 @@ package mirror
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"bitmap/config"
@@ -37,6 +38,71 @@ func MirrorVertically(pixels [][]*core.Pixel) [][]*core.Pixel {
 	return newPixels
 }
 
+// orientation describes how to turn an image stored with one of the 8 EXIF
+// orientations into the normal one. A target pixel is read from the source
+// at its own coordinates after the flips, swapped when transpose is set.
+type orientation struct {
+	flipX, flipY, transpose bool
+}
+
+var orientations = [9]orientation{
+	1: {},
+	2: {flipX: true},
+	3: {flipX: true, flipY: true},
+	4: {flipY: true},
+	5: {transpose: true},
+	6: {flipX: true, transpose: true},
+	7: {flipX: true, flipY: true, transpose: true},
+	8: {flipY: true, transpose: true},
+}
+
+// MirrorDiagonally reflects the image across the diagonal from the top-left
+// to the bottom-right corner (transpose).
+func MirrorDiagonally(pixels [][]*core.Pixel) [][]*core.Pixel {
+	return reorient(pixels, orientations[5])
+}
+
+// MirrorAntidiagonally reflects the image across the diagonal from the
+// top-right to the bottom-left corner (transverse).
+func MirrorAntidiagonally(pixels [][]*core.Pixel) [][]*core.Pixel {
+	return reorient(pixels, orientations[7])
+}
+
+// Orient turns an image stored with the given EXIF orientation (1 to 8)
+// into the normal orientation in a single pass.
+func Orient(pixels [][]*core.Pixel, exif int) [][]*core.Pixel {
+	return reorient(pixels, orientations[exif])
+}
+
+func reorient(pixels [][]*core.Pixel, o orientation) [][]*core.Pixel {
+	h, w := len(pixels), len(pixels[0])
+	outH, outW := h, w
+	if o.transpose {
+		outH, outW = w, h
+	}
+
+	newPixels := make([][]*core.Pixel, outH)
+	for r := range newPixels {
+		newPixels[r] = make([]*core.Pixel, outW)
+		// coordinates are from the top-left, rows are stored bottom-up
+		y := outH - 1 - r
+		for x := range newPixels[r] {
+			u, v := x, y
+			if o.flipX {
+				u = outW - 1 - u
+			}
+			if o.flipY {
+				v = outH - 1 - v
+			}
+			if o.transpose {
+				u, v = v, u
+			}
+			newPixels[r][x] = pixels[h-1-v][u]
+		}
+	}
+	return newPixels
+}
+
 func HandleMirror(bm *core.BitMap) {
 	if len(config.MirrorFlag) == 0 {
 		return
@@ -53,6 +119,14 @@ func HandleMirror(bm *core.BitMap) {
 	case strings.HasPrefix("vertically", cmd):
 		pixels = MirrorVertically(pixels)
 
+	case strings.HasPrefix("diagonal", cmd) || cmd == "transpose":
+		pixels = MirrorDiagonally(pixels)
+		height, width = width, height
+
+	case strings.HasPrefix("antidiagonal", cmd) || cmd == "transverse":
+		pixels = MirrorAntidiagonally(pixels)
+		height, width = width, height
+
 	default:
 		_, _ = fmt.Fprintln(os.Stderr, "ERROR: Invalid mirror command")
 		os.Exit(1)
@@ -61,4 +135,23 @@ func HandleMirror(bm *core.BitMap) {
 	config.MirrorFlag = config.MirrorFlag[1:]
 	bm.SetPixels(pixels)
 	bm.SetDimensions(height, width)
+	bm.UpdateSizes()
+}
+
+func HandleOrient(bm *core.BitMap) {
+	if len(config.OrientFlag) == 0 {
+		return
+	}
+
+	exif, err := strconv.Atoi(config.OrientFlag[0])
+	if err != nil || exif < 1 || exif > 8 {
+		_, _ = fmt.Fprintln(os.Stderr, "ERROR: Orientation must be a number from 1 to 8")
+		os.Exit(1)
+	}
+
+	pixels := Orient(bm.GetPixels(), exif)
+	config.OrientFlag = config.OrientFlag[1:]
+	bm.SetPixels(pixels)
+	bm.SetDimensions(int32(len(pixels)), int32(len(pixels[0])))
+	bm.UpdateSizes()
 }
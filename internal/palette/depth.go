@@ -0,0 +1,61 @@
+package palette
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"bitmap/config"
+	"bitmap/internal/core"
+)
+
+// defaultPalettes are used for a bit depth when --palette is not given.
+var defaultPalettes = map[int]string{
+	1: "bw",
+	4: "vga",
+	8: "websafe",
+}
+
+// CheckDither stops the program when --dither names an unknown method. It
+// runs before any processing, so a typo does not cost a full run.
+func CheckDither() {
+	if !slices.Contains(DitherMethods, config.DitherFlag) {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: unknown dithering method %q, use %s\n", config.DitherFlag, strings.Join(DitherMethods, ", "))
+		os.Exit(1)
+	}
+}
+
+// HandleDepth prepares the image for saving with config.BitDepth bits per
+// pixel. It reduces the image to the chosen palette, dithering it when
+// --dither is given, and hands the palette to the writer. A quantization
+// method as the palette name builds a palette from the image itself.
+func HandleDepth(b *core.BitMap) {
+	if config.BitDepth == 24 {
+		return
+	}
+
+	name := config.PaletteFlag
+	if name == "" {
+		name = defaultPalettes[config.BitDepth]
+	}
+	var p Palette
+	var err error
+	if slices.Contains(QuantizeMethods, name) {
+		p, err = Quantize(b, 1<<config.BitDepth, name)
+	} else {
+		p, err = Parse(name)
+	}
+	if err == nil && len(p) > 1<<config.BitDepth {
+		err = fmt.Errorf("palette %q has %d colors, %d bits per pixel hold at most %d", name, len(p), config.BitDepth, 1<<config.BitDepth)
+	}
+	if err == nil {
+		err = Dither(b, p, config.DitherFlag, 4)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	b.SetPalette(uint16(config.BitDepth), p)
+}
@@ -0,0 +1,165 @@
+package palette
+
+import (
+	"fmt"
+	"math"
+
+	"bitmap/internal/core"
+)
+
+// diffusion spreads the quantization error of a pixel to the neighbors that
+// have not been visited yet. Each entry is a column and row offset and the
+// share of the error that goes there.
+type diffusion []struct {
+	dx, dy int
+	weight float64
+}
+
+var diffusions = map[string]diffusion{
+	"floyd": {
+		{1, 0, 7.0 / 16},
+		{-1, 1, 3.0 / 16}, {0, 1, 5.0 / 16}, {1, 1, 1.0 / 16},
+	},
+	// Atkinson passes on only 6/8 of the error, which keeps contrast high.
+	"atkinson": {
+		{1, 0, 1.0 / 8}, {2, 0, 1.0 / 8},
+		{-1, 1, 1.0 / 8}, {0, 1, 1.0 / 8}, {1, 1, 1.0 / 8},
+		{0, 2, 1.0 / 8},
+	},
+	"jjn": {
+		{1, 0, 7.0 / 48}, {2, 0, 5.0 / 48},
+		{-2, 1, 3.0 / 48}, {-1, 1, 5.0 / 48}, {0, 1, 7.0 / 48}, {1, 1, 5.0 / 48}, {2, 1, 3.0 / 48},
+		{-2, 2, 1.0 / 48}, {-1, 2, 3.0 / 48}, {0, 2, 5.0 / 48}, {1, 2, 3.0 / 48}, {2, 2, 1.0 / 48},
+	},
+}
+
+// DitherMethods lists the accepted dithering methods.
+var DitherMethods = []string{"none", "floyd", "atkinson", "jjn", "bayer"}
+
+// Dither reduces the image to the palette with the named method. "bayer"
+// uses an ordered matrix of the given size (2, 4 or 8), "none" maps every
+// pixel to its nearest color.
+func Dither(b *core.BitMap, p Palette, method string, bayerSize int) error {
+	switch method {
+	case "none":
+		p.Map(b)
+	case "bayer":
+		if bayerSize != 2 && bayerSize != 4 && bayerSize != 8 {
+			return fmt.Errorf("bayer matrix size must be 2, 4 or 8, got %d", bayerSize)
+		}
+		orderedDither(b, p, bayerSize)
+	default:
+		d, ok := diffusions[method]
+		if !ok {
+			return fmt.Errorf("unknown dithering method %q", method)
+		}
+		diffuse(b, p, d)
+	}
+	return nil
+}
+
+// diffuse runs error diffusion from the top left corner. The error is kept
+// in floats so it is not lost to rounding between pixels.
+func diffuse(b *core.BitMap, p Palette, d diffusion) {
+	pixels := b.GetPixels()
+	h := len(pixels)
+	if h == 0 {
+		return
+	}
+	w := len(pixels[0])
+
+	work := make([][3]float64, w*h)
+	for y := 0; y < h; y++ {
+		// BMP rows are stored bottom-up
+		for x, pixel := range pixels[h-1-y] {
+			work[y*w+x] = [3]float64{float64(pixel.Red), float64(pixel.Green), float64(pixel.Blue)}
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		row := pixels[h-1-y]
+		for x := 0; x < w; x++ {
+			old := work[y*w+x]
+			c := p[p.Nearest(old[0], old[1], old[2])]
+			*row[x] = c
+			errs := [3]float64{old[0] - float64(c.Red), old[1] - float64(c.Green), old[2] - float64(c.Blue)}
+			for _, e := range d {
+				nx, ny := x+e.dx, y+e.dy
+				if nx < 0 || nx >= w || ny >= h {
+					continue
+				}
+				for ch := range errs {
+					work[ny*w+nx][ch] += errs[ch] * e.weight
+				}
+			}
+		}
+	}
+}
+
+// orderedDither offsets every pixel by a threshold from a Bayer matrix
+// before picking the nearest color. The offset spans the gap between the
+// two palette colors nearest to the pixel, so it fits evenly spaced grays
+// as well as color cubes and irregular palettes.
+func orderedDither(b *core.BitMap, p Palette, size int) {
+	matrix := bayer(size)
+	spreads := make(map[core.Pixel]float64)
+
+	pixels := b.GetPixels()
+	h := len(pixels)
+	for y := 0; y < h; y++ {
+		for x, pixel := range pixels[h-1-y] {
+			spread, ok := spreads[*pixel]
+			if !ok {
+				spread = p.gap(float64(pixel.Red), float64(pixel.Green), float64(pixel.Blue))
+				spreads[*pixel] = spread
+			}
+			t := (matrix[(y%size)*size+x%size]+0.5)/float64(size*size) - 0.5
+			offset := t * spread
+			*pixel = p[p.Nearest(float64(pixel.Red)+offset, float64(pixel.Green)+offset, float64(pixel.Blue)+offset)]
+		}
+	}
+}
+
+// gap returns the largest channel difference between the two palette
+// colors nearest to (r, g, b), the step a threshold has to cover there.
+func (p Palette) gap(r, g, b float64) float64 {
+	if len(p) < 2 {
+		return 0
+	}
+	first, second := -1, -1
+	var firstDist, secondDist float64
+	for i, c := range p {
+		dr, dg, db := float64(c.Red)-r, float64(c.Green)-g, float64(c.Blue)-b
+		dist := dr*dr + dg*dg + db*db
+		switch {
+		case first < 0 || dist < firstDist:
+			second, secondDist = first, firstDist
+			first, firstDist = i, dist
+		case second < 0 || dist < secondDist:
+			second, secondDist = i, dist
+		}
+	}
+	a, c := p[first], p[second]
+	return math.Max(math.Abs(float64(a.Red)-float64(c.Red)),
+		math.Max(math.Abs(float64(a.Green)-float64(c.Green)), math.Abs(float64(a.Blue)-float64(c.Blue))))
+}
+
+// bayer builds the size x size threshold matrix with values 0..size*size-1
+// by recursively tiling the 2x2 matrix.
+func bayer(size int) []float64 {
+	m := []float64{0}
+	for n := 1; n < size; n *= 2 {
+		next := make([]float64, 4*n*n)
+		for y := 0; y < n; y++ {
+			for x := 0; x < n; x++ {
+				v := 4 * m[y*n+x]
+				next[y*2*n+x] = v
+				next[y*2*n+x+n] = v + 2
+				next[(y+n)*2*n+x] = v + 3
+				next[(y+n)*2*n+x+n] = v + 1
+			}
+		}
+		m = next
+	}
+	return m
+}
@@ -0,0 +1,110 @@
+package palette
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"bitmap/internal/core"
+	"bitmap/internal/params"
+)
+
+// Palette is the list of colors an image is reduced to.
+type Palette []core.Pixel
+
+var named = map[string]func() Palette{
+	"bw":      BlackWhite,
+	"gray":    func() Palette { return Gray(16) },
+	"vga":     VGA,
+	"websafe": WebSafe,
+}
+
+func BlackWhite() Palette {
+	return Palette{{}, {Red: 255, Green: 255, Blue: 255}}
+}
+
+// Gray returns n evenly spaced shades from black to white.
+func Gray(n int) Palette {
+	p := make(Palette, n)
+	for i := range p {
+		v := byte(i * 255 / (n - 1))
+		p[i] = core.Pixel{Red: v, Green: v, Blue: v}
+	}
+	return p
+}
+
+// VGA returns the 16 colors of the standard VGA text mode.
+func VGA() Palette {
+	return Palette{
+		{}, {Red: 128}, {Green: 128}, {Red: 128, Green: 128},
+		{Blue: 128}, {Red: 128, Blue: 128}, {Green: 128, Blue: 128}, {Red: 192, Green: 192, Blue: 192},
+		{Red: 128, Green: 128, Blue: 128}, {Red: 255}, {Green: 255}, {Red: 255, Green: 255},
+		{Blue: 255}, {Red: 255, Blue: 255}, {Green: 255, Blue: 255}, {Red: 255, Green: 255, Blue: 255},
+	}
+}
+
+// WebSafe returns the 216 colors with every channel a multiple of 51.
+func WebSafe() Palette {
+	p := make(Palette, 0, 216)
+	for r := 0; r < 6; r++ {
+		for g := 0; g < 6; g++ {
+			for b := 0; b < 6; b++ {
+				p = append(p, core.Pixel{Red: byte(r * 51), Green: byte(g * 51), Blue: byte(b * 51)})
+			}
+		}
+	}
+	return p
+}
+
+// Parse returns a built-in palette by name, or a custom palette written
+// as a comma separated list of colors.
+func Parse(text string) (Palette, error) {
+	if p, ok := named[strings.ToLower(text)]; ok {
+		return p(), nil
+	}
+	var p Palette
+	for _, item := range strings.Split(text, ",") {
+		c, err := params.ParseColor(item)
+		if err != nil {
+			names := make([]string, 0, len(named))
+			for name := range named {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("palette %q: not a built-in palette (%s) or a list of colors: %v", text, strings.Join(names, ", "), err)
+		}
+		p = append(p, c)
+	}
+	if len(p) < 2 {
+		return nil, fmt.Errorf("palette %q: needs at least two colors", text)
+	}
+	return p, nil
+}
+
+// Nearest returns the index of the palette color closest to r, g, b.
+func (p Palette) Nearest(r, g, b float64) int {
+	best, bestDist := 0, math.Inf(1)
+	for i, c := range p {
+		dr, dg, db := float64(c.Red)-r, float64(c.Green)-g, float64(c.Blue)-b
+		if dist := dr*dr + dg*dg + db*db; dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+// Map replaces every pixel with its nearest palette color.
+func (p Palette) Map(b *core.BitMap) {
+	cache := make(map[core.Pixel]core.Pixel)
+	for _, row := range b.GetPixels() {
+		for _, pixel := range row {
+			c, ok := cache[*pixel]
+			if !ok {
+				c = p[p.Nearest(float64(pixel.Red), float64(pixel.Green), float64(pixel.Blue))]
+				cache[*pixel] = c
+			}
+			*pixel = c
+		}
+	}
+}
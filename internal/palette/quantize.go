@@ -0,0 +1,270 @@
+package palette
+
+import (
+	"fmt"
+	"sort"
+
+	"bitmap/internal/core"
+)
+
+// QuantizeMethods lists the accepted quantization methods.
+var QuantizeMethods = []string{"mediancut", "octree", "kmeans"}
+
+// colorCount is a distinct color of the image and how often it occurs.
+type colorCount struct {
+	c     [3]int
+	count int
+}
+
+// Quantize builds a palette of at most n colors that represents the image
+// well, with median cut, octree or k-means. The image itself is not
+// changed, use Dither to reduce it to the palette.
+func Quantize(b *core.BitMap, n int, method string) (Palette, error) {
+	if n < 2 || n > 256 {
+		return nil, fmt.Errorf("number of colors must be between 2 and 256, got %d", n)
+	}
+	colors := countColors(b)
+	if len(colors) <= n {
+		p := make(Palette, len(colors))
+		for i, cc := range colors {
+			p[i] = toPixel(cc.c)
+		}
+		return p, nil
+	}
+
+	switch method {
+	case "mediancut":
+		return medianCut(colors, n), nil
+	case "octree":
+		return octree(colors, n), nil
+	case "kmeans":
+		return kmeans(colors, medianCut(colors, n), 16), nil
+	default:
+		return nil, fmt.Errorf("unknown quantization method %q", method)
+	}
+}
+
+func countColors(b *core.BitMap) []colorCount {
+	counts := make(map[core.Pixel]int)
+	for _, row := range b.GetPixels() {
+		for _, pixel := range row {
+			counts[*pixel]++
+		}
+	}
+	colors := make([]colorCount, 0, len(counts))
+	for p, n := range counts {
+		colors = append(colors, colorCount{c: [3]int{int(p.Red), int(p.Green), int(p.Blue)}, count: n})
+	}
+	// map order is random, sort so the result is reproducible
+	sort.Slice(colors, func(i, j int) bool {
+		a, b := colors[i].c, colors[j].c
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		return a[2] < b[2]
+	})
+	return colors
+}
+
+func toPixel(c [3]int) core.Pixel {
+	return core.Pixel{Red: byte(c[0]), Green: byte(c[1]), Blue: byte(c[2])}
+}
+
+// average returns the count-weighted mean color.
+func average(colors []colorCount) core.Pixel {
+	var sum [3]int
+	var total int
+	for _, cc := range colors {
+		for ch := range sum {
+			sum[ch] += cc.c[ch] * cc.count
+		}
+		total += cc.count
+	}
+	for ch := range sum {
+		sum[ch] = (sum[ch] + total/2) / total
+	}
+	return toPixel(sum)
+}
+
+// medianCut repeatedly splits the box of colors with the widest channel
+// range at the median pixel of that channel until there are n boxes, and
+// returns the average color of each box.
+func medianCut(colors []colorCount, n int) Palette {
+	boxes := [][]colorCount{colors}
+	for len(boxes) < n {
+		// pick the box with the widest channel range that can still be split
+		best, bestChannel, bestRange := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for ch := 0; ch < 3; ch++ {
+				low, high := 255, 0
+				for _, cc := range box {
+					low, high = min(low, cc.c[ch]), max(high, cc.c[ch])
+				}
+				if high-low > bestRange {
+					best, bestChannel, bestRange = i, ch, high-low
+				}
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.SliceStable(box, func(i, j int) bool { return box[i].c[bestChannel] < box[j].c[bestChannel] })
+		var total int
+		for _, cc := range box {
+			total += cc.count
+		}
+		split, seen := 1, 0
+		for i, cc := range box[:len(box)-1] {
+			seen += cc.count
+			split = i + 1
+			if 2*seen >= total {
+				break
+			}
+		}
+		boxes[best] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+
+	p := make(Palette, len(boxes))
+	for i, box := range boxes {
+		p[i] = average(box)
+	}
+	return p
+}
+
+type octreeNode struct {
+	sum      [3]int
+	count    int
+	children [8]*octreeNode
+	leaf     bool
+}
+
+// octree inserts every color into an 8-level tree of color cube subdivisions
+// and merges the least used leaves of the deepest level into their parent
+// until at most n leaves remain. Each leaf gives one palette color.
+func octree(colors []colorCount, n int) Palette {
+	root := &octreeNode{}
+	levels := make([][]*octreeNode, 8)
+	leaves := 0
+	for _, cc := range colors {
+		node := root
+		for depth := 0; depth < 8; depth++ {
+			shift := 7 - depth
+			i := (cc.c[0]>>shift&1)<<2 | (cc.c[1]>>shift&1)<<1 | cc.c[2]>>shift&1
+			if node.children[i] == nil {
+				node.children[i] = &octreeNode{leaf: depth == 7}
+				if depth == 7 {
+					leaves++
+				} else {
+					levels[depth+1] = append(levels[depth+1], node.children[i])
+				}
+			}
+			node = node.children[i]
+		}
+		for ch := range node.sum {
+			node.sum[ch] += cc.c[ch] * cc.count
+		}
+		node.count += cc.count
+	}
+	levels[0] = []*octreeNode{root}
+
+	for depth := 7; depth >= 0 && leaves > n; depth-- {
+		nodes := levels[depth]
+		sort.SliceStable(nodes, func(i, j int) bool { return subtreeCount(nodes[i]) < subtreeCount(nodes[j]) })
+		for _, node := range nodes {
+			if leaves <= n {
+				break
+			}
+			merged := 0
+			for i, child := range node.children {
+				if child == nil {
+					continue
+				}
+				for ch := range node.sum {
+					node.sum[ch] += child.sum[ch]
+				}
+				node.count += child.count
+				node.children[i] = nil
+				merged++
+			}
+			node.leaf = true
+			leaves -= merged - 1
+		}
+	}
+
+	var p Palette
+	var collect func(node *octreeNode)
+	collect = func(node *octreeNode) {
+		if node.leaf {
+			p = append(p, toPixel([3]int{
+				(node.sum[0] + node.count/2) / node.count,
+				(node.sum[1] + node.count/2) / node.count,
+				(node.sum[2] + node.count/2) / node.count,
+			}))
+			return
+		}
+		for _, child := range node.children {
+			if child != nil {
+				collect(child)
+			}
+		}
+	}
+	collect(root)
+	return p
+}
+
+// subtreeCount returns the number of pixels below a node that has not been
+// merged yet. Merging happens bottom-up, so the children are leaves.
+func subtreeCount(node *octreeNode) int {
+	total := node.count
+	for _, child := range node.children {
+		if child != nil {
+			total += child.count
+		}
+	}
+	return total
+}
+
+// kmeans refines the palette with Lloyd's algorithm: assign every color to
+// its nearest palette entry, move each entry to the mean of its colors and
+// repeat until nothing moves or the iterations run out.
+func kmeans(colors []colorCount, p Palette, iterations int) Palette {
+	for it := 0; it < iterations; it++ {
+		sums := make([][3]int, len(p))
+		counts := make([]int, len(p))
+		for _, cc := range colors {
+			k := p.Nearest(float64(cc.c[0]), float64(cc.c[1]), float64(cc.c[2]))
+			for ch := range cc.c {
+				sums[k][ch] += cc.c[ch] * cc.count
+			}
+			counts[k] += cc.count
+		}
+
+		moved := false
+		for k := range p {
+			if counts[k] == 0 {
+				continue
+			}
+			c := toPixel([3]int{
+				(sums[k][0] + counts[k]/2) / counts[k],
+				(sums[k][1] + counts[k]/2) / counts[k],
+				(sums[k][2] + counts[k]/2) / counts[k],
+			})
+			if c != p[k] {
+				p[k], moved = c, true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+	return p
+}
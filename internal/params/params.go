@@ -0,0 +1,240 @@
+package params
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"bitmap/internal/core"
+)
+
+// Kind is the type of value a parameter accepts.
+type Kind int
+
+const (
+	Int Kind = iota
+	Float
+	String
+	Bool
+	// Color is a hex color like "ff8800" or "#ff8800", or a color name.
+	Color
+	// ColorList is a comma separated list of colors.
+	ColorList
+	// FloatList is a comma separated list of numbers.
+	FloatList
+)
+
+var namedColors = map[string]core.Pixel{
+	"black":   {},
+	"white":   {Red: 255, Green: 255, Blue: 255},
+	"gray":    {Red: 128, Green: 128, Blue: 128},
+	"red":     {Red: 255},
+	"green":   {Green: 255},
+	"blue":    {Blue: 255},
+	"yellow":  {Red: 255, Green: 255},
+	"cyan":    {Green: 255, Blue: 255},
+	"magenta": {Red: 255, Blue: 255},
+}
+
+// Spec describes a single named parameter of a command option.
+// Min and Max bound numeric values when either of them is non-zero, for a
+// ColorList Min is the smallest number of colors. Choices restricts string
+// values to a fixed set.
+type Spec struct {
+	Name    string
+	Kind    Kind
+	Default string
+	Min     float64
+	Max     float64
+	Choices []string
+}
+
+// Values holds parsed parameter values keyed by parameter name.
+type Values map[string]any
+
+// Split separates an option like "blur:radius=3" into its name ("blur")
+// and the raw parameter list ("radius=3").
+func Split(arg string) (string, string) {
+	name, raw, _ := strings.Cut(arg, ":")
+	return name, raw
+}
+
+// Parse parses a raw "key=value:key=value" list against specs and fills in
+// defaults for the keys that were not given. The owner is used in error
+// messages, e.g. `filter "blur"`.
+func Parse(owner, raw string, specs []Spec) (Values, error) {
+	given := make(map[string]string)
+	if raw != "" {
+		for _, pair := range strings.Split(raw, ":") {
+			key, value, ok := strings.Cut(pair, "=")
+			key = strings.ToLower(strings.TrimSpace(key))
+			if !ok || key == "" {
+				return nil, fmt.Errorf("%s: parameter %q must be written as key=value", owner, pair)
+			}
+			if _, dup := given[key]; dup {
+				return nil, fmt.Errorf("%s: parameter %q is given more than once", owner, key)
+			}
+			given[key] = value
+		}
+	}
+
+	known := make(map[string]Spec, len(specs))
+	for _, s := range specs {
+		known[s.Name] = s
+	}
+	for key := range given {
+		if _, ok := known[key]; !ok {
+			return nil, unknownError(owner, key, specs)
+		}
+	}
+
+	values := make(Values, len(specs))
+	for _, s := range specs {
+		text, ok := given[s.Name]
+		if !ok {
+			text = s.Default
+		}
+		v, err := s.parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: parameter %q %v", owner, s.Name, err)
+		}
+		values[s.Name] = v
+	}
+	return values, nil
+}
+
+func (s Spec) parse(text string) (any, error) {
+	switch s.Kind {
+	case Int:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer, got %q", text)
+		}
+		if err := s.checkRange(float64(n)); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case Float:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a number, got %q", text)
+		}
+		if err := s.checkRange(f); err != nil {
+			return nil, err
+		}
+		return f, nil
+	case Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false, got %q", text)
+		}
+		return b, nil
+	case Color:
+		return ParseColor(text)
+	case ColorList:
+		var colors []core.Pixel
+		for _, item := range strings.Split(text, ",") {
+			c, err := ParseColor(item)
+			if err != nil {
+				return nil, err
+			}
+			colors = append(colors, c)
+		}
+		if len(colors) < int(s.Min) {
+			return nil, fmt.Errorf("needs at least %d colors, got %d", int(s.Min), len(colors))
+		}
+		return colors, nil
+	case FloatList:
+		var list []float64
+		for _, item := range strings.Split(text, ",") {
+			f, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+			if err != nil {
+				return nil, fmt.Errorf("must be a list of numbers, got %q", item)
+			}
+			list = append(list, f)
+		}
+		return list, nil
+	default:
+		text = strings.TrimSpace(text)
+		// an empty default marks an optional parameter that was not given
+		if len(s.Choices) > 0 && (text != "" || s.Default != "") {
+			lower := strings.ToLower(text)
+			for _, c := range s.Choices {
+				if c == lower {
+					return lower, nil
+				}
+			}
+			return nil, fmt.Errorf("must be one of %s, got %q", strings.Join(s.Choices, ", "), text)
+		}
+		return text, nil
+	}
+}
+
+// ParseColor parses a color written as six or three hex digits with an
+// optional leading '#', or as one of the basic color names.
+func ParseColor(text string) (core.Pixel, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if c, ok := namedColors[text]; ok {
+		return c, nil
+	}
+	hex := strings.TrimPrefix(text, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return core.Pixel{}, fmt.Errorf("must be a color like ff8800 or a color name, got %q", text)
+	}
+	return core.Pixel{Red: byte(v >> 16), Green: byte(v >> 8), Blue: byte(v)}, nil
+}
+
+func (s Spec) checkRange(f float64) error {
+	if s.Min == 0 && s.Max == 0 {
+		return nil
+	}
+	if f < s.Min || f > s.Max {
+		return fmt.Errorf("must be between %g and %g, got %g", s.Min, s.Max, f)
+	}
+	return nil
+}
+
+func unknownError(owner, key string, specs []Spec) error {
+	if len(specs) == 0 {
+		return fmt.Errorf("%s: unknown parameter %q, it takes no parameters", owner, key)
+	}
+	names := make([]string, 0, len(specs))
+	for _, s := range specs {
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("%s: unknown parameter %q (valid: %s)", owner, key, strings.Join(names, ", "))
+}
+
+func (v Values) Int(name string) int {
+	return v[name].(int)
+}
+
+func (v Values) Float(name string) float64 {
+	return v[name].(float64)
+}
+
+func (v Values) String(name string) string {
+	return v[name].(string)
+}
+
+func (v Values) Bool(name string) bool {
+	return v[name].(bool)
+}
+
+func (v Values) Color(name string) core.Pixel {
+	return v[name].(core.Pixel)
+}
+
+func (v Values) Colors(name string) []core.Pixel {
+	return v[name].([]core.Pixel)
+}
+
+func (v Values) Floats(name string) []float64 {
+	return v[name].([]float64)
+}
@@ -0,0 +1,204 @@
+package resample
+
+import (
+	"math"
+
+	"bitmap/internal/core"
+)
+
+// Kernel is a reconstruction filter used to compute a pixel from its
+// neighbors. Weight is zero outside [-Support, Support].
+type Kernel struct {
+	Support float64
+	Weight  func(x float64) float64
+}
+
+var Kernels = map[string]Kernel{
+	"nearest": {Support: 0.5, Weight: func(x float64) float64 {
+		if x >= -0.5 && x < 0.5 {
+			return 1
+		}
+		return 0
+	}},
+	"bilinear": {Support: 1, Weight: func(x float64) float64 {
+		return math.Max(0, 1-math.Abs(x))
+	}},
+	"bicubic": {Support: 2, Weight: cubic},
+	"lanczos": {Support: 3, Weight: func(x float64) float64 {
+		if x <= -3 || x >= 3 {
+			return 0
+		}
+		return sinc(x) * sinc(x/3)
+	}},
+}
+
+// KernelNames lists the accepted kernel names.
+var KernelNames = []string{"nearest", "bilinear", "bicubic", "lanczos"}
+
+// cubic is the Keys cubic convolution kernel with a = -0.5 (Catmull-Rom).
+func cubic(x float64) float64 {
+	x = math.Abs(x)
+	switch {
+	case x < 1:
+		return 1.5*x*x*x - 2.5*x*x + 1
+	case x < 2:
+		return -0.5*x*x*x + 2.5*x*x - 4*x + 2
+	default:
+		return 0
+	}
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+// contribution is the list of source indexes and normalized weights that
+// make up one destination coordinate.
+type contribution struct {
+	index  []int
+	weight []float64
+}
+
+// contributions computes the weights for scaling srcSize to dstSize. When
+// shrinking, the kernel is stretched by the scale so every source pixel
+// contributes and no aliasing appears. Nearest neighbor is never stretched.
+func contributions(srcSize, dstSize int, k Kernel, nearest bool) []contribution {
+	scale := float64(srcSize) / float64(dstSize)
+	stretch := math.Max(1, scale)
+	if nearest {
+		stretch = 1
+	}
+	support := k.Support * stretch
+
+	out := make([]contribution, dstSize)
+	for i := range out {
+		center := (float64(i)+0.5)*scale - 0.5
+		if nearest {
+			j := min(int(math.Floor((float64(i)+0.5)*scale)), srcSize-1)
+			out[i] = contribution{index: []int{j}, weight: []float64{1}}
+			continue
+		}
+		low := int(math.Ceil(center - support))
+		high := int(math.Floor(center + support))
+		var c contribution
+		var sum float64
+		for j := low; j <= high; j++ {
+			w := k.Weight((float64(j) - center) / stretch)
+			if w == 0 {
+				continue
+			}
+			c.index = append(c.index, min(max(j, 0), srcSize-1))
+			c.weight = append(c.weight, w)
+			sum += w
+		}
+		for n := range c.weight {
+			c.weight[n] /= sum
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// Resize scales the pixels to width x height with the given kernel, as a
+// horizontal pass followed by a vertical pass.
+func Resize(pixels [][]*core.Pixel, width, height int, kernelName string) [][]*core.Pixel {
+	srcH := len(pixels)
+	srcW := len(pixels[0])
+	k := Kernels[kernelName]
+	nearest := kernelName == "nearest"
+
+	cols := contributions(srcW, width, k, nearest)
+	tmp := make([][3]float64, srcH*width)
+	for y, row := range pixels {
+		for x, c := range cols {
+			var sum [3]float64
+			for n, j := range c.index {
+				w := c.weight[n]
+				sum[0] += w * float64(row[j].Red)
+				sum[1] += w * float64(row[j].Green)
+				sum[2] += w * float64(row[j].Blue)
+			}
+			tmp[y*width+x] = sum
+		}
+	}
+
+	rows := contributions(srcH, height, k, nearest)
+	out := make([][]*core.Pixel, height)
+	for y, c := range rows {
+		out[y] = make([]*core.Pixel, width)
+		for x := 0; x < width; x++ {
+			var sum [3]float64
+			for n, j := range c.index {
+				w := c.weight[n]
+				v := tmp[j*width+x]
+				sum[0] += w * v[0]
+				sum[1] += w * v[1]
+				sum[2] += w * v[2]
+			}
+			out[y][x] = &core.Pixel{Red: ToByte(sum[0]), Green: ToByte(sum[1]), Blue: ToByte(sum[2])}
+		}
+	}
+	return out
+}
+
+// ToByte rounds v and clamps it to a byte. Sharp kernels like bicubic and
+// Lanczos overshoot near edges.
+func ToByte(v float64) byte {
+	if v <= 0 || math.IsNaN(v) {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return byte(v + 0.5)
+}
+
+// Sample interpolates the pixels at (x, y), given in pixel indexes, with the
+// named kernel. Taps that fall outside the image take the background color,
+// so borders blend smoothly into it.
+func Sample(pixels [][]*core.Pixel, x, y float64, kernelName string, bg core.Pixel) *core.Pixel {
+	k := Kernels[kernelName]
+	h := len(pixels)
+	w := len(pixels[0])
+
+	x0, x1 := int(math.Ceil(x-k.Support)), int(math.Floor(x+k.Support))
+	y0, y1 := int(math.Ceil(y-k.Support)), int(math.Floor(y+k.Support))
+	if x1 < 0 || y1 < 0 || x0 >= w || y0 >= h {
+		return &core.Pixel{Red: bg.Red, Green: bg.Green, Blue: bg.Blue}
+	}
+
+	var wx [8]float64
+	for i := x0; i <= x1; i++ {
+		wx[i-x0] = k.Weight(float64(i) - x)
+	}
+	var sum [3]float64
+	var total float64
+	for j := y0; j <= y1; j++ {
+		wy := k.Weight(float64(j) - y)
+		if wy == 0 {
+			continue
+		}
+		for i := x0; i <= x1; i++ {
+			weight := wx[i-x0] * wy
+			if weight == 0 {
+				continue
+			}
+			c := &bg
+			if i >= 0 && i < w && j >= 0 && j < h {
+				c = pixels[j][i]
+			}
+			sum[0] += weight * float64(c.Red)
+			sum[1] += weight * float64(c.Green)
+			sum[2] += weight * float64(c.Blue)
+			total += weight
+		}
+	}
+	if total == 0 {
+		return &core.Pixel{Red: bg.Red, Green: bg.Green, Blue: bg.Blue}
+	}
+	return &core.Pixel{Red: ToByte(sum[0] / total), Green: ToByte(sum[1] / total), Blue: ToByte(sum[2] / total)}
+}
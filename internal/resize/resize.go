@@ -0,0 +1,133 @@
+package resize
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"bitmap/config"
+	"bitmap/internal/core"
+	"bitmap/internal/params"
+	"bitmap/internal/resample"
+)
+
+// maxSide keeps the result within what the BMP header and memory allow.
+const maxSide = 1 << 15
+
+var resizeParams = []params.Spec{
+	{Name: "mode", Kind: params.String, Default: "stretch", Choices: []string{"stretch", "fit", "fill"}},
+	{Name: "kernel", Kind: params.String, Default: "bicubic", Choices: resample.KernelNames},
+}
+
+func HandleResize(b *core.BitMap) {
+	if len(config.ResizeFlag) == 0 {
+		return
+	}
+
+	size, raw := params.Split(config.ResizeFlag[0])
+	values, err := params.Parse("resize", raw, resizeParams)
+	if err == nil {
+		err = resize(b, size, values.String("mode"), values.String("kernel"))
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	config.ResizeFlag = config.ResizeFlag[1:]
+}
+
+func resize(b *core.BitMap, size, mode, kernel string) error {
+	height, width := b.GetDimensions()
+	if width == 0 || height == 0 {
+		return fmt.Errorf("resize: image is empty")
+	}
+	fw, fh, err := parseSize(size, float64(width), float64(height))
+	if err != nil {
+		return err
+	}
+
+	// fit and fill keep the aspect ratio, scaling to the inner or outer
+	// side of the box
+	cropW, cropH := fw, fh
+	if mode != "stretch" {
+		sx, sy := fw/float64(width), fh/float64(height)
+		scale := math.Min(sx, sy)
+		if mode == "fill" {
+			scale = math.Max(sx, sy)
+		}
+		fw = math.Max(1, math.Round(float64(width)*scale))
+		fh = math.Max(1, math.Round(float64(height)*scale))
+		if mode == "fit" {
+			cropW, cropH = fw, fh
+		}
+	}
+	// compare before converting, a huge float does not fit an int
+	if fw > maxSide || fh > maxSide {
+		return fmt.Errorf("resize: %gx%g is larger than %dx%d", fw, fh, maxSide, maxSide)
+	}
+	w, h := int(fw), int(fh)
+
+	pixels := resample.Resize(b.GetPixels(), w, h, kernel)
+	if int(cropW) != w || int(cropH) != h {
+		pixels = cropCenter(pixels, int(cropW), int(cropH))
+	}
+
+	b.SetPixels(pixels)
+	b.SetDimensions(int32(len(pixels)), int32(len(pixels[0])))
+	b.UpdateSizes()
+	return nil
+}
+
+// parseSize reads "WxH", "Wx" or "xH" (the missing side keeps the aspect
+// ratio) or a percentage like "50%". The size is returned unconverted so
+// the caller can check it against maxSide first.
+func parseSize(size string, width, height float64) (float64, float64, error) {
+	if percent, ok := strings.CutSuffix(size, "%"); ok {
+		p, err := strconv.ParseFloat(percent, 64)
+		if err != nil || !(p > 0) || math.IsInf(p, 0) {
+			return 0, 0, fmt.Errorf("resize: invalid percentage %q", size)
+		}
+		return math.Max(1, math.Round(width*p/100)), math.Max(1, math.Round(height*p/100)), nil
+	}
+
+	ws, hs, ok := strings.Cut(strings.ToLower(size), "x")
+	if !ok || ws == "" && hs == "" {
+		return 0, 0, fmt.Errorf("resize: size must be WxH, Wx, xH or N%%, got %q", size)
+	}
+	var w, h float64
+	if ws != "" {
+		n, err := strconv.Atoi(ws)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("resize: invalid width %q", ws)
+		}
+		w = float64(n)
+	}
+	if hs != "" {
+		n, err := strconv.Atoi(hs)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("resize: invalid height %q", hs)
+		}
+		h = float64(n)
+	}
+	if w == 0 {
+		w = math.Max(1, math.Round(width*h/height))
+	}
+	if h == 0 {
+		h = math.Max(1, math.Round(height*w/width))
+	}
+	return w, h, nil
+}
+
+// cropCenter cuts a width x height window out of the middle of the pixels.
+func cropCenter(pixels [][]*core.Pixel, width, height int) [][]*core.Pixel {
+	top := (len(pixels) - height) / 2
+	left := (len(pixels[0]) - width) / 2
+	out := make([][]*core.Pixel, height)
+	for y := range out {
+		out[y] = pixels[top+y][left : left+width]
+	}
+	return out
+}
@@ -0,0 +1,39 @@
+package rotate
+
+import (
+	"math"
+
+	"bitmap/internal/core"
+	"bitmap/internal/resample"
+)
+
+// RotateAngle rotates the pixels clockwise by degrees around the center of
+// the image, sampling the source with the named kernel. With expand the
+// canvas grows to hold the whole rotated image, otherwise it keeps its size
+// and the corners are cut off. Uncovered areas are filled with bg.
+func RotateAngle(pixels [][]*core.Pixel, degrees float64, kernel string, expand bool, bg core.Pixel) [][]*core.Pixel {
+	h, w := len(pixels), len(pixels[0])
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+
+	newW, newH := w, h
+	if expand {
+		// the small tolerance keeps multiples of 90 from growing by a pixel
+		newW = int(math.Ceil(math.Abs(float64(w)*cos) + math.Abs(float64(h)*sin) - 1e-6))
+		newH = int(math.Ceil(math.Abs(float64(w)*sin) + math.Abs(float64(h)*cos) - 1e-6))
+	}
+
+	// rows are stored bottom-up, so a clockwise turn on screen is a
+	// negative angle here; every target pixel is mapped back to the source
+	rotated := make([][]*core.Pixel, newH)
+	for y := range rotated {
+		rotated[y] = make([]*core.Pixel, newW)
+		dy := float64(y) + 0.5 - float64(newH)/2
+		for x := range rotated[y] {
+			dx := float64(x) + 0.5 - float64(newW)/2
+			sx := cos*dx - sin*dy + float64(w)/2 - 0.5
+			sy := sin*dx + cos*dy + float64(h)/2 - 0.5
+			rotated[y][x] = resample.Sample(pixels, sx, sy, kernel, bg)
+		}
+	}
+	return rotated
+}
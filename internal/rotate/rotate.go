@@ -2,11 +2,15 @@ package rotate
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"strconv"
 	"strings"
 
 	"bitmap/config"
 	"bitmap/internal/core"
+	"bitmap/internal/params"
+	"bitmap/internal/resample"
 )
 
 var globalHeight, globalWidth int32
@@ -22,6 +26,12 @@ var rotationMap = map[string]int{
 	"-180":  2,
 }
 
+var rotateParams = []params.Spec{
+	{Name: "interp", Kind: params.String, Default: "bilinear", Choices: resample.KernelNames},
+	{Name: "expand", Kind: params.Bool, Default: "true"},
+	{Name: "bg", Kind: params.Color, Default: "000000"},
+}
+
 func RotateBMP(pixels [][]*core.Pixel) [][]*core.Pixel {
 	rotated := make([][]*core.Pixel, globalWidth)
 	for x := int32(0); x < globalWidth; x++ {
@@ -52,17 +62,35 @@ func HandleRotate(b *core.BitMap) {
 	pixels := b.GetPixels()
 	globalHeight, globalWidth = b.GetDimensions()
 
-	rotation, exists := rotationMap[strings.ToLower(config.RotateFlag[0])]
-	if !exists {
-		_, _ = fmt.Fprintln(os.Stderr, "ERROR: The rotation flag is specified incorrectly")
+	name, raw := params.Split(config.RotateFlag[0])
+	values, err := params.Parse("rotate", raw, rotateParams)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 		os.Exit(1)
 	}
 
-	if rotation > 0 {
-		pixels = rotateImage(pixels, rotation)
+	// quarter turns that keep the whole image are exact, anything else is
+	// resampled
+	rotation, exists := rotationMap[strings.ToLower(name)]
+	if exists && values.Bool("expand") {
+		if rotation > 0 {
+			pixels = rotateImage(pixels, rotation)
+		}
+	} else {
+		angle := float64(-90 * rotation)
+		if !exists {
+			angle, err = strconv.ParseFloat(name, 64)
+			if err != nil || math.IsNaN(angle) || math.IsInf(angle, 0) {
+				_, _ = fmt.Fprintln(os.Stderr, "ERROR: The rotation flag is specified incorrectly")
+				os.Exit(1)
+			}
+		}
+		pixels = RotateAngle(pixels, angle, values.String("interp"), values.Bool("expand"), values.Color("bg"))
+		globalHeight, globalWidth = int32(len(pixels)), int32(len(pixels[0]))
 	}
 
 	config.RotateFlag = config.RotateFlag[1:]
 	b.SetPixels(pixels)
 	b.SetDimensions(globalHeight, globalWidth)
+	b.UpdateSizes()
 }
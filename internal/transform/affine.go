@@ -0,0 +1,100 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"bitmap/config"
+	"bitmap/internal/core"
+	"bitmap/internal/params"
+)
+
+// Affine is a 2x3 matrix that maps a point (x, y) to
+// (A*x + B*y + C, D*x + E*y + F). Coordinates are pixel indexes with the
+// origin at the top-left pixel and y growing downwards.
+type Affine struct {
+	A, B, C float64
+	D, E, F float64
+}
+
+var affineParams = append([]params.Spec{
+	{Name: "expand", Kind: params.Bool, Default: "false"},
+}, samplingParams...)
+
+func (m Affine) apply(x, y float64) (float64, float64) {
+	return m.A*x + m.B*y + m.C, m.D*x + m.E*y + m.F
+}
+
+func (m Affine) invert() (Affine, error) {
+	det := m.A*m.E - m.B*m.D
+	if math.Abs(det) < 1e-12 {
+		return Affine{}, fmt.Errorf("affine: the matrix is not invertible")
+	}
+	a, b, d, e := m.E/det, -m.B/det, -m.D/det, m.A/det
+	return Affine{
+		A: a, B: b, C: -(a*m.C + b*m.F),
+		D: d, E: e, F: -(d*m.C + e*m.F),
+	}, nil
+}
+
+// ApplyAffine maps the image through m. Without expand the result keeps
+// the source size, with it the canvas is the bounding box of the mapped
+// image. Areas outside the source are filled with bg.
+func ApplyAffine(b *core.BitMap, m Affine, expand bool, kernel string, bg core.Pixel) error {
+	pixels := b.GetPixels()
+	h, w := len(pixels), len(pixels[0])
+
+	width, height := w, h
+	if expand {
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		// the outer edges of the corner pixels
+		for _, c := range [][2]float64{{-0.5, -0.5}, {float64(w) - 0.5, -0.5}, {-0.5, float64(h) - 0.5}, {float64(w) - 0.5, float64(h) - 0.5}} {
+			x, y := m.apply(c[0], c[1])
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+		fw := math.Ceil(maxX - minX - 1e-6)
+		fh := math.Ceil(maxY - minY - 1e-6)
+		if !(fw <= 1<<15 && fh <= 1<<15) {
+			return fmt.Errorf("affine: the result would be %gx%g", fw, fh)
+		}
+		width, height = int(fw), int(fh)
+		m.C -= minX + 0.5
+		m.F -= minY + 0.5
+	}
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("affine: the result is empty")
+	}
+
+	inverse, err := m.invert()
+	if err != nil {
+		return err
+	}
+	setPixels(b, warp(pixels, width, height, inverse.apply, kernel, bg))
+	return nil
+}
+
+func HandleAffine(b *core.BitMap) {
+	if len(config.AffineFlag) == 0 {
+		return
+	}
+
+	matrix, raw := params.Split(config.AffineFlag[0])
+	values, err := params.Parse("affine", raw, affineParams)
+	var n []float64
+	if err == nil {
+		n, err = parseNumbers("affine", matrix, 6)
+	}
+	if err == nil {
+		m := Affine{A: n[0], B: n[1], C: n[2], D: n[3], E: n[4], F: n[5]}
+		err = ApplyAffine(b, m, values.Bool("expand"), values.String("interp"), values.Color("bg"))
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	config.AffineFlag = config.AffineFlag[1:]
+}
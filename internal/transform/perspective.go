@@ -0,0 +1,151 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"bitmap/config"
+	"bitmap/internal/core"
+	"bitmap/internal/params"
+)
+
+var perspectiveParams = append([]params.Spec{
+	{Name: "size", Kind: params.String},
+}, samplingParams...)
+
+// homography is a 3x3 projective matrix with its last entry fixed to 1.
+type homography [8]float64
+
+func (m homography) apply(x, y float64) (float64, float64) {
+	w := m[6]*x + m[7]*y + 1
+	return (m[0]*x + m[1]*y + m[2]) / w, (m[3]*x + m[4]*y + m[5]) / w
+}
+
+// solveHomography finds the matrix that maps every from point onto the
+// matching to point.
+func solveHomography(from, to [4][2]float64) (homography, error) {
+	var a [8][9]float64
+	for i := range 4 {
+		u, v := from[i][0], from[i][1]
+		x, y := to[i][0], to[i][1]
+		a[2*i] = [9]float64{u, v, 1, 0, 0, 0, -u * x, -v * x, x}
+		a[2*i+1] = [9]float64{0, 0, 0, u, v, 1, -u * y, -v * y, y}
+	}
+
+	// Gaussian elimination with partial pivoting
+	for col := range 8 {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-9 {
+			return homography{}, fmt.Errorf("perspective: three of the corners lie on one line")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := range 8 {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+
+	var m homography
+	for i := range m {
+		m[i] = a[i][8] / a[i][i]
+	}
+	return m, nil
+}
+
+// ApplyPerspective maps the quadrilateral with the corners top-left,
+// top-right, bottom-right and bottom-left onto a width x height rectangle.
+// A zero size is estimated from the longer of the opposite sides.
+func ApplyPerspective(b *core.BitMap, corners [4][2]float64, width, height int, kernel string, bg core.Pixel) error {
+	if !convex(corners) {
+		return fmt.Errorf("perspective: the corners must form a convex quadrilateral in the order top-left, top-right, bottom-right, bottom-left")
+	}
+	if width == 0 || height == 0 {
+		dist := func(p, q [2]float64) float64 {
+			return math.Hypot(p[0]-q[0], p[1]-q[1])
+		}
+		fw := math.Round(math.Max(dist(corners[0], corners[1]), dist(corners[3], corners[2]))) + 1
+		fh := math.Round(math.Max(dist(corners[0], corners[3]), dist(corners[1], corners[2]))) + 1
+		if !(fw <= 1<<15 && fh <= 1<<15) {
+			return fmt.Errorf("perspective: the result would be %gx%g", fw, fh)
+		}
+		width, height = int(fw), int(fh)
+	}
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("perspective: the result is empty")
+	}
+
+	// the target corners are the centers of the corner pixels
+	w, h := float64(width-1), float64(height-1)
+	target := [4][2]float64{{0, 0}, {w, 0}, {w, h}, {0, h}}
+	m, err := solveHomography(target, corners)
+	if err != nil {
+		return err
+	}
+	setPixels(b, warp(b.GetPixels(), width, height, m.apply, kernel, bg))
+	return nil
+}
+
+// convex reports whether the corners turn the same way at every vertex.
+func convex(corners [4][2]float64) bool {
+	var sign float64
+	for i := range 4 {
+		p, q, r := corners[i], corners[(i+1)%4], corners[(i+2)%4]
+		cross := (q[0]-p[0])*(r[1]-q[1]) - (q[1]-p[1])*(r[0]-q[0])
+		// written so that NaN also fails
+		if !(math.Abs(cross) >= 1e-9) || sign*cross < 0 {
+			return false
+		}
+		sign = cross
+	}
+	return true
+}
+
+func HandlePerspective(b *core.BitMap) {
+	if len(config.PerspectiveFlag) == 0 {
+		return
+	}
+
+	points, raw := params.Split(config.PerspectiveFlag[0])
+	values, err := params.Parse("perspective", raw, perspectiveParams)
+	var n []float64
+	if err == nil {
+		n, err = parseNumbers("perspective", points, 8)
+	}
+	var width, height int
+	if err == nil && values.String("size") != "" {
+		width, height, err = parseSize(values.String("size"))
+	}
+	if err == nil {
+		corners := [4][2]float64{{n[0], n[1]}, {n[2], n[3]}, {n[4], n[5]}, {n[6], n[7]}}
+		err = ApplyPerspective(b, corners, width, height, values.String("interp"), values.Color("bg"))
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	config.PerspectiveFlag = config.PerspectiveFlag[1:]
+}
+
+func parseSize(text string) (int, int, error) {
+	ws, hs, _ := strings.Cut(strings.ToLower(text), "x")
+	w, errW := strconv.Atoi(ws)
+	h, errH := strconv.Atoi(hs)
+	if errW != nil || errH != nil || w <= 0 || h <= 0 || w > 1<<15 || h > 1<<15 {
+		return 0, 0, fmt.Errorf("perspective: size must be WxH, got %q", text)
+	}
+	return w, h, nil
+}
@@ -0,0 +1,62 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"bitmap/internal/core"
+	"bitmap/internal/params"
+	"bitmap/internal/resample"
+)
+
+// mapping returns the source position, in pixel indexes with y growing
+// downwards, that the target pixel (x, y) is read from.
+type mapping func(x, y float64) (float64, float64)
+
+// warp builds a width x height image by sampling the source at the position
+// m gives for every target pixel.
+func warp(pixels [][]*core.Pixel, width, height int, m mapping, kernel string, bg core.Pixel) [][]*core.Pixel {
+	h := len(pixels)
+	out := make([][]*core.Pixel, height)
+	for r := range out {
+		out[r] = make([]*core.Pixel, width)
+		// BMP rows are stored bottom-up
+		y := float64(height - 1 - r)
+		for x := range out[r] {
+			sx, sy := m(float64(x), y)
+			out[r][x] = resample.Sample(pixels, sx, float64(h-1)-sy, kernel, bg)
+		}
+	}
+	return out
+}
+
+func setPixels(b *core.BitMap, pixels [][]*core.Pixel) {
+	b.SetPixels(pixels)
+	b.SetDimensions(int32(len(pixels)), int32(len(pixels[0])))
+	b.UpdateSizes()
+}
+
+// parseNumbers reads a comma separated list of exactly n numbers.
+func parseNumbers(owner, text string, n int) ([]float64, error) {
+	items := strings.Split(text, ",")
+	if len(items) != n {
+		return nil, fmt.Errorf("%s: needs %d comma separated numbers, got %d", owner, n, len(items))
+	}
+	numbers := make([]float64, n)
+	for i, item := range items {
+		v, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("%s: %q is not a finite number", owner, item)
+		}
+		numbers[i] = v
+	}
+	return numbers, nil
+}
+
+// samplingParams are shared by all transforms.
+var samplingParams = []params.Spec{
+	{Name: "interp", Kind: params.String, Default: "bilinear", Choices: resample.KernelNames},
+	{Name: "bg", Kind: params.Color, Default: "000000"},
+}
@@ -7,17 +7,26 @@ import (
 	"bitmap/config"
 	"bitmap/internal/core"
 	"bitmap/internal/crop"
+	"bitmap/internal/deskew"
 	"bitmap/internal/filter"
 	"bitmap/internal/header"
 	"bitmap/internal/mirror"
+	"bitmap/internal/palette"
+	"bitmap/internal/resize"
 	"bitmap/internal/rotate"
+	"bitmap/internal/transform"
 )
 
 var applyFeatures = map[string]func(*core.BitMap){
-	"filter": filter.HandleFilter,
-	"rotate": rotate.HandleRotate,
-	"mirror": mirror.HandleMirror,
-	"crop":   crop.HandleCrop,
+	"filter":      filter.HandleFilter,
+	"rotate":      rotate.HandleRotate,
+	"mirror":      mirror.HandleMirror,
+	"crop":        crop.HandleCrop,
+	"resize":      resize.HandleResize,
+	"deskew":      deskew.HandleDeskew,
+	"affine":      transform.HandleAffine,
+	"perspective": transform.HandlePerspective,
+	"orient":      mirror.HandleOrient,
 }
 
 func main() {
@@ -37,9 +46,14 @@ func main() {
 	}
 
 	if config.ApplyCmd != nil {
+		palette.CheckDither()
 		for _, feature := range config.OrderedFlags {
-			applyFeatures[feature](b)
+			// options like --bpp are not processing steps
+			if apply, ok := applyFeatures[feature]; ok {
+				apply(b)
+			}
 		}
+		palette.HandleDepth(b)
 		file, err = os.Create(config.OutputFileName)
 		if err != nil {
 			_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)